@@ -0,0 +1,203 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var validBundleName = regexp.MustCompile(`^[a-zA-Z0-9_-]+\.(css|js)$`)
+
+// Content types of the supported bundle types
+var bundleTypes = map[string]string{
+	".css": "text/css; charset=utf-8",
+	".js":  "application/javascript; charset=utf-8",
+}
+
+// A bundle of stylesheets or scripts, concatenated and minified
+type Bundle struct {
+	// The name of the bundle, for example "site.css"
+	Name string
+	// The content type of the bundle
+	ContentType string
+	// The concatenated and minified content
+	Content []byte
+	// The content hash, used in fingerprinted URLs
+	Hash string
+	// When the bundle was built
+	BuiltAt time.Time
+}
+
+// Bundles of stylesheets and scripts. The bundles are built when the content is checked out and kept in memory
+type Bundles struct {
+	root    string
+	prefix  string
+	cached  bool
+	configs map[string]config.BundleConfig
+	mux     sync.Mutex
+	bundles map[string]*Bundle
+}
+
+// Build the bundle with the supplied name by reading all of its files
+func (b *Bundles) build(name string, cfg config.BundleConfig) (*Bundle, error) {
+	ext := path.Ext(name)
+	var buf bytes.Buffer
+	for _, file := range cfg.Files {
+		uri := path.Clean("/" + file)
+		if isHidden(uri) {
+			return nil, fmt.Errorf("file '%s' is hidden", file)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(b.root, filepath.FromSlash(uri)))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		// Scripts without a trailing semicolon must not be merged with the next script
+		if ext == ".js" {
+			buf.WriteString("\n;")
+		}
+		buf.WriteByte('\n')
+	}
+
+	content := buf.Bytes()
+	if ext == ".css" {
+		content = MinifyCSS(content)
+	} else {
+		content = MinifyJS(content)
+	}
+	hash := sha256.Sum256(content)
+	return &Bundle{
+		Name:        name,
+		ContentType: bundleTypes[ext],
+		Content:     content,
+		Hash:        hex.EncodeToString(hash[:])[:hashLength],
+		BuiltAt:     time.Now().UTC(),
+	}, nil
+}
+
+// Search for the bundle with the supplied name, for example "site.css"
+func (b *Bundles) Find(name string) (*Bundle, error) {
+	cfg, ok := b.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("bundle '%s' is not found", name)
+	}
+	if !b.cached {
+		return b.build(name, cfg)
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if bundle, ok := b.bundles[name]; ok {
+		return bundle, nil
+	}
+	return nil, fmt.Errorf("bundle '%s' is not built", name)
+}
+
+// Fetch the fingerprinted URL of the bundle with the supplied name, for example "/assets/bundles/site.3f2a9c81b0d4.css"
+func (b *Bundles) URL(name string) (string, error) {
+	bundle, err := b.Find(name)
+	if err != nil {
+		return "", err
+	}
+	ext := path.Ext(name)
+	return b.prefix + "/" + strings.TrimSuffix(name, ext) + "." + bundle.Hash + ext, nil
+}
+
+// Split the supplied URL into the name of the bundle and the hash. The hash is empty if the URL isn't
+// fingerprinted. Will return false if the URL isn't the URL of a bundle
+func (b *Bundles) Parse(uri string) (string, string, bool) {
+	if !strings.HasPrefix(uri, b.prefix+"/") {
+		return "", "", false
+	}
+	name := uri[len(b.prefix)+1:]
+	if _, ok := b.configs[name]; ok {
+		return name, "", true
+	}
+
+	original, hash, ok := splitFingerprint(name)
+	if !ok {
+		return "", "", false
+	}
+	if _, ok := b.configs[original]; !ok {
+		return "", "", false
+	}
+	return original, hash, true
+}
+
+// Build all bundles. A bundle that can't be built is kept as it was last built, if it was built before, while the
+// other bundles are replaced. An error is returned if any bundle can't be built
+func (b *Bundles) load(ctx context.Context) error {
+	if !b.cached {
+		return nil
+	}
+
+	var result error
+	bundles := make(map[string]*Bundle)
+	for name, cfg := range b.configs {
+		log.Infof(ctx, "Building bundle %s", name)
+		bundle, err := b.build(name, cfg)
+		if err != nil {
+			result = NewLoadError("Could not build bundle: '%s' because: %v", name, err)
+			log.Errorf(ctx, "%v", result)
+			if bundle, err = b.Find(name); err != nil {
+				continue
+			}
+		}
+		bundles[name] = bundle
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.bundles = bundles
+	return result
+}
+
+// Rebuild the bundles when content is checked out. Errors are logged instead of returned, since the bundles that
+// can't be built keep being served as they were, and returning an error would stop other listeners from reloading
+func (b *Bundles) OnEvent(ctx context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		if err := b.load(ctx); err != nil {
+			log.Warnf(ctx, "Serving the previous version of bundles that could not be built")
+		}
+	}
+	return nil
+}
+
+// Create new bundles of the static files found in the supplied content directory. The bundles are served with the
+// supplied uri prefix, for example "/assets/bundles". The bundles are built up front and when content is checked
+// out if cached is true. Otherwise, the bundles are built each time they are needed
+func NewBundles(bus *event.Bus, contentDirectory string, prefix string, configs map[string]config.BundleConfig,
+	cached bool) *Bundles {
+	for name := range configs {
+		if !validBundleName.MatchString(name) {
+			panic(NewLoadError("Invalid bundle name: '%s'. Expected a name such as 'site.css' or 'app.js'", name))
+		}
+	}
+
+	impl := &Bundles{
+		root:    contentDirectory,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		cached:  cached,
+		configs: configs,
+		mux:     sync.Mutex{},
+		bundles: make(map[string]*Bundle),
+	}
+	err := impl.load(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	bus.AddListener(impl)
+	return impl
+}
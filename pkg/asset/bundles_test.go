@@ -0,0 +1,70 @@
+package asset
+
+import (
+	"context"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Listener that records the events it's notified about
+type recorder struct {
+	events []interface{}
+}
+
+func (r *recorder) OnEvent(ctx context.Context, e interface{}) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestBundleIsKeptWhenItCantBeBuilt(t *testing.T) {
+	root, err := ioutil.TempDir("", "bundles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	write := func(name string, data string) {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.css", "a { color: red; }")
+	write("b.css", "b { color: blue; }")
+
+	bus := event.NewBus()
+	bundles := NewBundles(bus, root, "/assets/bundles", map[string]config.BundleConfig{
+		"a.css": {Files: []string{"a.css"}},
+		"b.css": {Files: []string{"b.css"}},
+	}, true)
+	listener := &recorder{}
+	bus.AddListener(listener)
+
+	if err := os.Remove(filepath.Join(root, "a.css")); err != nil {
+		t.Fatal(err)
+	}
+	write("b.css", "b { color: green; }")
+	if err := bus.NotifyAll(context.Background(), &event.Checkout{Commit: "HEAD"}); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if len(listener.events) != 1 {
+		t.Error("expected listeners after the bundles to be notified")
+	}
+
+	a, err := bundles.Find("a.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a.Content) != "a{color:red}" {
+		t.Errorf("expected the previous bundle but got %q", a.Content)
+	}
+	b, err := bundles.Find("b.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b.Content) != "b{color:green}" {
+		t.Errorf("expected the bundle to be rebuilt but got %q", b.Content)
+	}
+}
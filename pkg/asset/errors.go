@@ -0,0 +1,18 @@
+package asset
+
+import "fmt"
+
+// Error raised when the content hashes of the static files could not be calculated
+type LoadError struct {
+	message string
+}
+
+func (l *LoadError) Error() string {
+	return l.message
+}
+
+func NewLoadError(format string, v ...interface{}) *LoadError {
+	return &LoadError{
+		message: fmt.Sprintf(format, v...),
+	}
+}
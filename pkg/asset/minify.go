@@ -0,0 +1,227 @@
+package asset
+
+import (
+	"bytes"
+	"strings"
+)
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Find the end of the quoted string starting at the supplied position. Will return the position after the
+// closing quote
+func skipString(src []byte, i int) int {
+	quote := src[i]
+	for i++; i < len(src); i++ {
+		if src[i] == '\\' {
+			i++
+		} else if src[i] == quote {
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// Find the end of the template literal starting at the supplied position. Expressions in the template literal,
+// such as "${name}", may contain strings and other template literals. Will return the position after the closing
+// backtick
+func skipTemplate(src []byte, i int) int {
+	for i++; i < len(src); i++ {
+		if src[i] == '\\' {
+			i++
+		} else if src[i] == '`' {
+			return i + 1
+		} else if src[i] == '$' && i+1 < len(src) && src[i+1] == '{' {
+			i = skipExpression(src, i+2) - 1
+		}
+	}
+	return len(src)
+}
+
+// Find the end of the template literal expression starting at the supplied position. Will return the position
+// after the closing brace
+func skipExpression(src []byte, i int) int {
+	depth := 0
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '"' || c == '\'':
+			i = skipString(src, i)
+		case c == '`':
+			i = skipTemplate(src, i)
+		case c == '}' && depth == 0:
+			return i + 1
+		default:
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+			}
+			i++
+		}
+	}
+	return len(src)
+}
+
+// Minify the supplied stylesheet by removing comments and whitespace that isn't needed
+func MinifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	// Characters where whitespace on both sides can be removed
+	const separators = "{};,>"
+	// Characters where whitespace after them can be removed. Whitespace before a colon is significant in
+	// selectors, such as "a :hover"
+	const after = separators + ":"
+	space := false
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case isSpace(c):
+			space = true
+			i++
+		case c == '"' || c == '\'':
+			if space && out.Len() > 0 && !strings.ContainsRune(after, rune(out.Bytes()[out.Len()-1])) {
+				out.WriteByte(' ')
+			}
+			space = false
+			end := skipString(src, i)
+			out.Write(src[i:end])
+			i = end
+		default:
+			if c == '}' && out.Len() > 0 && out.Bytes()[out.Len()-1] == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			if space && out.Len() > 0 && !strings.ContainsRune(separators, rune(c)) &&
+				!strings.ContainsRune(after, rune(out.Bytes()[out.Len()-1])) {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// Check to see if a slash following the supplied output starts a regular expression instead of a division
+func startsRegExp(out []byte) bool {
+	if len(out) == 0 || strings.ContainsRune("(,=:[!&|?{};+-*%<>~^", rune(out[len(out)-1])) {
+		return true
+	}
+	return endsWithKeyword(out, "return", "typeof", "case", "do", "else", "in", "of", "void")
+}
+
+// Check to see if the supplied output ends with one of the supplied keywords
+func endsWithKeyword(out []byte, keywords ...string) bool {
+	for _, keyword := range keywords {
+		if bytes.HasSuffix(out, []byte(keyword)) {
+			start := len(out) - len(keyword)
+			if start == 0 || !isIdentifier(out[start-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Minify the supplied script by removing comments, indentation and empty lines. Line breaks are kept, since
+// removing them could change the meaning of a script that relies on automatic semicolon insertion
+func MinifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	newline := false
+	// For each open parenthesis, if it belongs to a statement such as "if (...)". A slash after the closing
+	// parenthesis of such a statement starts a regular expression instead of a division
+	var parens []bool
+	statement := false
+	write := func(b []byte) {
+		if newline && out.Len() > 0 {
+			out.WriteByte('\n')
+		} else if space && out.Len() > 0 {
+			out.WriteByte(' ')
+		}
+		space = false
+		newline = false
+		out.Write(b)
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				if bytes.IndexByte(src[i:i+2+end], '\n') >= 0 {
+					newline = true
+				}
+				i += end + 4
+			}
+			space = true
+		case c == '\n' || c == '\r':
+			newline = true
+			i++
+		case isSpace(c):
+			space = true
+			i++
+		case c == '"' || c == '\'':
+			end := skipString(src, i)
+			write(src[i:end])
+			i = end
+		case c == '`':
+			end := skipTemplate(src, i)
+			write(src[i:end])
+			i = end
+		case c == '/' && (startsRegExp(out.Bytes()) || statement && bytes.HasSuffix(out.Bytes(), []byte(")"))):
+			// Copy the regular expression as-is, including character classes that may contain slashes
+			end := i + 1
+			class := false
+			for ; end < len(src) && src[end] != '\n'; end++ {
+				if src[end] == '\\' {
+					end++
+				} else if src[end] == '[' {
+					class = true
+				} else if src[end] == ']' {
+					class = false
+				} else if src[end] == '/' && !class {
+					end++
+					break
+				}
+			}
+			if end > len(src) {
+				end = len(src)
+			}
+			write(src[i:end])
+			i = end
+		default:
+			if c == '(' {
+				parens = append(parens, endsWithKeyword(out.Bytes(), "if", "while", "for", "with"))
+			} else if c == ')' && len(parens) > 0 {
+				statement = parens[len(parens)-1]
+				parens = parens[:len(parens)-1]
+			}
+			write(src[i : i+1])
+			i++
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,55 @@
+package asset
+
+import "testing"
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"comments", "/* reset */\nbody {\n  margin: 0; /* none */\n}\n", "body{margin:0}"},
+		{"selectors", "a :hover , p > span {\n  color : red ;\n}", "a :hover,p>span{color :red}"},
+		{"strings", `a::after { content: "  /* kept */  " ; }`, `a::after{content:"  /* kept */  "}`},
+		{"escaped quotes", `a { content: 'it\'s  }' }`, `a{content:'it\'s  }'}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := string(MinifyCSS([]byte(test.src))); actual != test.expected {
+				t.Errorf("expected %q but got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"comments", "// header\nvar a = 1; /* inline */ var b = 2;\n\n\n  call(a, b)\n", "var a = 1; var b = 2;\ncall(a, b)"},
+		{"strings", `var s = "// not a comment", t = '/* nor this */';`, `var s = "// not a comment", t = '/* nor this */';`},
+		{"template literal", "var s = `a  // b\n  c`;", "var s = `a  // b\n  c`;"},
+		{
+			"nested template literals",
+			"var s = `a ${ b ? `c ${ d } // e` : '}' } f`; // comment\n",
+			"var s = `a ${ b ? `c ${ d } // e` : '}' } f`;",
+		},
+		{"object in template literal", "var s = `${ {a: `}`}.a }`; // comment", "var s = `${ {a: `}`}.a }`;"},
+		{"regular expression", "var r = /\\/\\/ [/*]/g; // comment", "var r = /\\/\\/ [/*]/g;"},
+		{"regular expression after keyword", "return /a\\/b/.test(s) // comment", "return /a\\/b/.test(s)"},
+		{"regular expression after if", "if (ok) /a  b/.test(s) // comment", "if (ok) /a  b/.test(s)"},
+		{"regular expression after while", "while ((a)) /\\//.exec(s) // comment", "while ((a)) /\\//.exec(s)"},
+		{"division after parenthesis", "var a = (b + c) / 2 // comment /", "var a = (b + c) / 2"},
+		{"division after call", "if (x) y = f(a) / g(b) // comment /", "if (x) y = f(a) / g(b)"},
+		{"division after identifier", "var a = b / c / d // comment", "var a = b / c / d"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := string(MinifyJS([]byte(test.src))); actual != test.expected {
+				t.Errorf("expected %q but got %q", test.expected, actual)
+			}
+		})
+	}
+}
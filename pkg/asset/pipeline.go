@@ -0,0 +1,197 @@
+package asset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The number of hex characters of the content hash used in fingerprinted URLs
+const hashLength = 12
+
+// Matches the fingerprint of an URL, for example ".3f2a9c81b0d4" in "/assets/css/site.3f2a9c81b0d4.css"
+var fingerprint = regexp.MustCompile(`\.([0-9a-f]{12})(\.[^./]+)?$`)
+
+// Pipeline that keeps track of the content hashes of all static files. The hashes are used in fingerprinted URLs,
+// which change whenever the content of a file changes. This means that fingerprinted URLs can be cached forever.
+type Pipeline struct {
+	root   string
+	prefix string
+	cached bool
+	mux    sync.Mutex
+	hashes map[string]string
+}
+
+// Check to see if the supplied uri contains hidden files or directories, such as ".git"
+func isHidden(uri string) bool {
+	for _, part := range strings.Split(uri, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculate the content hash of the file at the supplied path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLength], nil
+}
+
+// Fetch the path on disk of the static file with the supplied uri, for example "/assets/css/site.css"
+func (p *Pipeline) Path(uri string) string {
+	return filepath.Join(p.root, filepath.FromSlash(path.Clean("/"+uri)))
+}
+
+// Fetch the content hash of the static file with the supplied uri. Files added after the hashes are calculated are
+// hashed when they are first requested. Will return false if the file doesn't exist
+func (p *Pipeline) Hash(uri string) (string, bool) {
+	if !strings.HasPrefix(uri, p.prefix+"/") || isHidden(uri) {
+		return "", false
+	}
+
+	if p.cached {
+		p.mux.Lock()
+		hash, ok := p.hashes[uri]
+		p.mux.Unlock()
+		if ok {
+			return hash, true
+		}
+	}
+
+	hash, err := hashFile(p.Path(uri))
+	if err != nil {
+		return "", false
+	}
+	if p.cached {
+		p.mux.Lock()
+		p.hashes[uri] = hash
+		p.mux.Unlock()
+	}
+	return hash, true
+}
+
+// Fetch the fingerprinted URL of the static file with the supplied uri, for example "/assets/css/site.css" becomes
+// "/assets/css/site.3f2a9c81b0d4.css". The uri is returned as-is if the file doesn't exist
+func (p *Pipeline) URL(uri string) string {
+	hash, ok := p.Hash(uri)
+	if !ok {
+		return uri
+	}
+	ext := path.Ext(uri)
+	return uri[:len(uri)-len(ext)] + "." + hash + ext
+}
+
+// Split the supplied fingerprinted URL into the uri without the fingerprint and the hash. Will return false if the
+// URL isn't fingerprinted
+func splitFingerprint(uri string) (string, string, bool) {
+	match := fingerprint.FindStringSubmatchIndex(uri)
+	if match == nil {
+		return "", "", false
+	}
+	hash := uri[match[2]:match[3]]
+	original := uri[:match[0]]
+	if match[4] >= 0 {
+		original += uri[match[4]:match[5]]
+	}
+	return original, hash, true
+}
+
+// Split the supplied fingerprinted URL into the uri of the static file and the hash. Will return false if the URL
+// isn't fingerprinted
+func (p *Pipeline) Parse(uri string) (string, string, bool) {
+	return splitFingerprint(uri)
+}
+
+// Calculate the content hashes of all static files
+func (p *Pipeline) load(ctx context.Context) error {
+	if !p.cached {
+		return nil
+	}
+
+	directory := p.Path(p.prefix)
+	log.Infof(ctx, "Calculating content hashes of static files in %s", directory)
+	hashes := make(map[string]string)
+	err := filepath.Walk(directory, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && name != directory {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(directory, name)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(name)
+		if err != nil {
+			return err
+		}
+		hashes[p.prefix+"/"+filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return NewLoadError("Could not calculate content hashes of: '%s' because: %v", directory, err)
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.hashes = hashes
+	return nil
+}
+
+func (p *Pipeline) OnEvent(ctx context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		if err := p.load(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create a new pipeline for the static files served with the supplied uri prefix from the supplied content
+// directory. The hashes are calculated up front and when content is checked out if cached is true. Otherwise,
+// the hashes are calculated each time they are needed, which is useful when files are edited directly on disk
+func NewPipeline(bus *event.Bus, contentDirectory string, prefix string, cached bool) *Pipeline {
+	impl := &Pipeline{
+		root:   contentDirectory,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		cached: cached,
+		mux:    sync.Mutex{},
+		hashes: make(map[string]string),
+	}
+	err := impl.load(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	bus.AddListener(impl)
+	return impl
+}
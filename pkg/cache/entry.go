@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// A cached page
+type Entry struct {
+	// The http status of the page
+	StatusCode int
+	// The headers sent with the page, such as Content-Type
+	Header http.Header
+	// The uncompressed content of the page
+	Content []byte
+	// Compressed versions of the content, keyed by content encoding. For example "gzip"
+	Encoded map[string][]byte
+	// A hash of the uncompressed content, used as ETag
+	Hash string
+	// When the page was rendered
+	LastModified time.Time
+}
+
+// Create a new cache entry. Headers that are specific to a single user, such as Set-Cookie, are never cached
+func NewEntry(statusCode int, header http.Header, content []byte, encoded map[string][]byte) *Entry {
+	h := make(http.Header)
+	for key, values := range header {
+		h[key] = append([]string(nil), values...)
+	}
+	h.Del("Set-Cookie")
+
+	hash := sha256.Sum256(content)
+	return &Entry{
+		StatusCode:   statusCode,
+		Header:       h,
+		Content:      content,
+		Encoded:      encoded,
+		Hash:         hex.EncodeToString(hash[:16]),
+		LastModified: time.Now().UTC().Truncate(time.Second),
+	}
+}
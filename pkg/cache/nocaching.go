@@ -4,11 +4,11 @@ package cache
 type NoCaching struct {
 }
 
-func (n NoCaching) Find(path string) ([]byte, error) {
-	return nil, nil
+func (n NoCaching) Find(path string) (*Entry, error) {
+	return nil, &PageNotFound{path}
 }
 
-func (n NoCaching) Set(path string, content []byte) {
+func (n NoCaching) Set(path string, entry *Entry) {
 }
 
 func (n NoCaching) IsAllowed(path string) bool {
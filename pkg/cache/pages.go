@@ -2,10 +2,10 @@ package cache
 
 type Pages interface {
 	// Try to find a cached page. This will return an error if the cache do not contain the supplied page path
-	Find(path string) ([]byte, error)
+	Find(path string) (*Entry, error)
 
 	// Set the cache for the supplied path
-	Set(path string, content []byte)
+	Set(path string, entry *Entry)
 
 	// Forcefully reset the cache
 	Reset()
@@ -19,38 +19,38 @@ type cacheDatabaseBody struct {
 // Reset is normally called when a new sync request happens
 type PermanentCache struct {
 	mux          sync.Mutex
-	data         map[string][]byte
+	data         map[string]*Entry
 	databasePath string
 	database     cacheDatabaseBody
 }
 
-func (p *PermanentCache) Find(path string) ([]byte, error) {
+func (p *PermanentCache) Find(path string) (*Entry, error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 	if c, ok := p.data[path]; ok {
 		return c, nil
 	}
-	return []byte{}, &PageNotFound{path}
+	return nil, &PageNotFound{path}
 }
 
-func (p *PermanentCache) Set(path string, content []byte) {
+func (p *PermanentCache) Set(path string, entry *Entry) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
-	p.data[path] = content
+	p.data[path] = entry
 }
 
 func (p *PermanentCache) Reset() {
 	log.Println("Resetting cache")
 	p.mux.Lock()
 	defer p.mux.Unlock()
-	p.data = make(map[string][]byte)
+	p.data = make(map[string]*Entry)
 }
 
 func (p *PermanentCache) OnEvent(ctx context.Context, e interface{}) error {
 	if _, ok := e.(*event.Checkout); ok {
 		err := p.load()
 		if err != nil {
-			log.Printf("Could not load page cache database. Reason: %e\n", err)
+			log.Printf("Could not load page cache database. Reason: %v\n", err)
 			return err
 		}
 		p.Reset()
@@ -87,14 +87,14 @@ func (p *PermanentCache) load() error {
 	log.Printf(`Loading cache database from "%s"`+"\n", p.databasePath)
 	bytes, err := ioutil.ReadFile(p.databasePath)
 	if err != nil {
-		log.Printf(`Could not read database file "%s". Reason: %e\n`, p.databasePath, err)
+		log.Printf(`Could not read database file "%s". Reason: %v\n`, p.databasePath, err)
 		return err
 	}
 
 	var body cacheDatabaseBody
 	err = json.Unmarshal(bytes, &body)
 	if err != nil {
-		log.Printf(`Could not parse database "%s". Reason: %e\n`, p.databasePath, err)
+		log.Printf(`Could not parse database "%s". Reason: %v\n`, p.databasePath, err)
 		return err
 	}
 
@@ -108,7 +108,7 @@ func (p *PermanentCache) load() error {
 // is updated. This is managed by listening for specific events on the event bus.
 func NewPermanentCache(bus *event.Bus, databasePath string) *PermanentCache {
 	impl := &PermanentCache{
-		data:         make(map[string][]byte),
+		data:         make(map[string]*Entry),
 		databasePath: databasePath,
 	}
 	if len(databasePath) > 0 {
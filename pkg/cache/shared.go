@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+)
+
+const SharedKey = "shared"
+
+// Mark the page being rendered as shared. Shared pages are cached and served to all users, which means that they
+// must not contain anything that only the current user is allowed to see
+func SetShared(ctx context.Context) context.Context {
+	return context.WithValue(ctx, SharedKey, true)
+}
+
+// Check to see if the page being rendered is shared between all users
+func IsShared(ctx context.Context) bool {
+	shared, _ := ctx.Value(SharedKey).(bool)
+	return shared
+}
+
+// Fetch the user that lists of pages, such as related pages and archives, are filtered for. Shared pages are served
+// to all users, which means that they may only list pages that users that are not logged in can see
+func Viewer(ctx context.Context, user *security.User) *security.User {
+	if IsShared(ctx) {
+		return security.NotLoggedInUser
+	}
+	return user
+}
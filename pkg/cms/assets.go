@@ -0,0 +1,71 @@
+package cms
+
+import (
+	"bytes"
+	"github.com/westcoastcode-se/gocms/pkg/asset"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"net/http"
+)
+
+// Serve the static file of a fingerprinted URL, such as "/assets/css/site.3f2a9c81b0d4.css". The content of a
+// fingerprinted URL never changes, which means that it can be cached forever. Requests for an outdated hash are
+// redirected to the current one. The static file itself is served by the supplied handler. Will return false if
+// the URL isn't fingerprinted
+func serveFingerprinted(pipeline *asset.Pipeline, handler http.Handler, ctx *RequestContext) bool {
+	rw := ctx.Response
+	r := ctx.Request
+
+	uri, hash, ok := pipeline.Parse(r.URL.Path)
+	if !ok {
+		return false
+	}
+	current, ok := pipeline.Hash(uri)
+	if !ok {
+		return false
+	}
+
+	if hash != current {
+		rw.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(rw, r, pipeline.URL(uri), http.StatusFound)
+		return true
+	}
+
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	original := r.Clone(r.Context())
+	original.URL.Path = uri
+	handler.ServeHTTP(rw, original)
+	return true
+}
+
+// Serve a bundle of stylesheets or scripts. Fingerprinted URLs, such as "/assets/bundles/site.3f2a9c81b0d4.css",
+// are cached forever and requests for an outdated hash are redirected to the current one. Will return false if the
+// URL isn't the URL of a bundle
+func serveBundle(bundles *asset.Bundles, ctx *RequestContext) bool {
+	rw := ctx.Response
+	r := ctx.Request
+
+	name, hash, ok := bundles.Parse(r.URL.Path)
+	if !ok {
+		return false
+	}
+	bundle, err := bundles.Find(name)
+	if err != nil {
+		log.Warnf(r.Context(), "Could not serve bundle %s: %v", name, err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Could not build bundle")
+		return true
+	}
+
+	if hash == "" {
+		rw.Header().Set("Cache-Control", "no-cache")
+	} else if hash != bundle.Hash {
+		url, _ := bundles.URL(name)
+		rw.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(rw, r, url, http.StatusFound)
+		return true
+	} else {
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	rw.Header().Set("Content-Type", bundle.ContentType)
+	http.ServeContent(rw, r, bundle.Name, bundle.BuiltAt, bytes.NewReader(bundle.Content))
+	return true
+}
@@ -25,7 +25,7 @@ func login(loginService auth.LoginService, tokenizer jwt.Tokenizer, ctx *Request
 	defer ctx.Request.Body.Close()
 	rw := ctx.Response
 	if err != nil {
-		log.Warnf(ctx.Request.Context(), "Could not parse request: %e", err.Error())
+		log.Warnf(ctx.Request.Context(), "Could not parse request: %v", err.Error())
 		returnErrorResponse(rw, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
@@ -33,14 +33,14 @@ func login(loginService auth.LoginService, tokenizer jwt.Tokenizer, ctx *Request
 	log.Infof(ctx.Request.Context(), "Trying to logging in user: %s", body.Username)
 	user, err := loginService.Login(body.Username, body.Password)
 	if err != nil {
-		log.Warnf(ctx.Request.Context(), "Invalid username or password for user: %e", err.Error())
+		log.Warnf(ctx.Request.Context(), "Invalid username or password for user: %v", err.Error())
 		returnErrorResponse(rw, http.StatusUnauthorized, "Invalid username or password")
 		return
 	}
 
 	result, err := tokenizer.UserToToken(user)
 	if err != nil {
-		log.Warnf(ctx.Request.Context(), "Could not create token for user: %e", err.Error())
+		log.Warnf(ctx.Request.Context(), "Could not create token for user: %v", err.Error())
 		returnErrorResponse(rw, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
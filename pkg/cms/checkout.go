@@ -2,10 +2,12 @@ package cms
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/westcoastcode-se/gocms/pkg/content"
 	"github.com/westcoastcode-se/gocms/pkg/log"
 	"github.com/westcoastcode-se/gocms/pkg/security"
 	"net/http"
+	"strings"
 )
 
 type CheckoutRequest struct {
@@ -16,6 +18,39 @@ type CheckoutResponse struct {
 	Commit string
 }
 
+// Error returned when a checkout fails. Contains the git command that failed and what it wrote to stderr
+type CheckoutErrorResponse struct {
+	Code    int
+	Message string
+	Command string
+	Stderr  string
+}
+
+func returnCheckoutError(rw http.ResponseWriter, commit string, err error) {
+	response := &CheckoutErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: "Could not checkout: " + commit,
+	}
+	if errors.Is(err, content.ErrInvalidRef) {
+		response.Code = http.StatusBadRequest
+	}
+	var gitErr *content.GitError
+	if errors.As(err, &gitErr) {
+		response.Command = "git " + strings.Join(gitErr.Args, " ")
+		response.Message += ": " + gitErr.Err.Error()
+		response.Stderr = gitErr.Stderr
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(response.Code)
+	_, _ = rw.Write(jsonResponse)
+}
+
 func checkout(controller content.Controller, ctx *RequestContext) {
 	user := ctx.User
 	rw := ctx.Response
@@ -31,15 +66,15 @@ func checkout(controller content.Controller, ctx *RequestContext) {
 		err := decoder.Decode(&body)
 		defer ctx.Request.Body.Close()
 		if err != nil {
-			log.Warnf(ctx.Request.Context(), "Could not checkout content: %e", err)
+			log.Warnf(ctx.Request.Context(), "Could not checkout content: %v", err)
 			returnErrorResponse(rw, http.StatusInternalServerError, "Could not checkout new content")
 			return
 		}
 
 		err = controller.Update(ctx.Request.Context(), body.Commit)
 		if err != nil {
-			log.Warnf(ctx.Request.Context(), "Could not pull content from remove server: %e", err)
-			returnErrorResponse(rw, http.StatusInternalServerError, "Could not checkout: "+body.Commit)
+			log.Warnf(ctx.Request.Context(), "Could not pull content from remove server: %v", err)
+			returnCheckoutError(rw, body.Commit, err)
 			return
 		}
 
@@ -0,0 +1,378 @@
+package cms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The content of a page, as returned by the content API
+type ContentResponse struct {
+	Path      string
+	ID        string
+	CreatedAt time.Time
+	View      string
+	Type      string
+	Tags      []string
+	Meta      content.Meta
+	Content   interface{}
+	Version   string
+}
+
+// The content of a page, as sent when a page is updated through the content API
+type ContentRequest struct {
+	ID           string
+	CreatedAt    time.Time
+	View         string
+	Type         string
+	Tags         []string
+	Meta         content.Meta
+	MetaDefaults content.Meta
+	Content      json.RawMessage
+}
+
+// Error returned when a page is updated based on a version that's no longer the latest version. Contains both
+// the latest version and the submitted version, so that the changes can be merged
+type ContentConflictResponse struct {
+	Code      int
+	Message   string
+	Current   *ContentResponse
+	Submitted *ContentResponse
+}
+
+func newContentResponse(path string, model *content.Model) *ContentResponse {
+	return &ContentResponse{
+		Path:      path,
+		ID:        model.ID,
+		CreatedAt: model.CreatedAt,
+		View:      model.View,
+		Type:      model.Type,
+		Tags:      model.Tags,
+		Meta:      model.Meta,
+		Content:   model.Content,
+		Version:   model.Version,
+	}
+}
+
+// Convert the supplied value into a generic JSON document
+func toDocument(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Split a comma separated list of field names, for example "Path,Content.Headline"
+func splitFields(value string) [][]string {
+	var result [][]string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			result = append(result, strings.Split(field, "."))
+		}
+	}
+	return result
+}
+
+// Replace the ID references found at the supplied field with the referenced content. References to content
+// the user is not allowed to read are left as-is.
+func expandField(repository content.Repository, service acl.Service, user *security.User,
+	document map[string]interface{}, field []string) {
+	value, ok := document[field[0]]
+	if !ok {
+		return
+	}
+
+	if len(field) > 1 {
+		if child, ok := value.(map[string]interface{}); ok {
+			expandField(repository, service, user, child, field[1:])
+		}
+		return
+	}
+
+	expand := func(value interface{}) interface{} {
+		id, ok := value.(string)
+		if !ok {
+			return value
+		}
+
+		result := repository.Lookup(id)
+		if result == nil || !acl.IsAccessible(service, user, result.Path) {
+			return value
+		}
+
+		child, err := toDocument(newContentResponse(result.Path, result.Model))
+		if err != nil {
+			return value
+		}
+		return child
+	}
+
+	if values, ok := value.([]interface{}); ok {
+		for i := range values {
+			values[i] = expand(values[i])
+		}
+		return
+	}
+	document[field[0]] = expand(value)
+}
+
+// Create a copy of the supplied document that only contains the supplied fields
+func projectFields(document map[string]interface{}, fields [][]string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, field := range fields {
+		source := document
+		target := result
+		for i, name := range field {
+			value, ok := source[name]
+			if !ok {
+				break
+			}
+
+			if i == len(field)-1 {
+				target[name] = value
+				break
+			}
+
+			child, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if _, ok := target[name].(map[string]interface{}); !ok {
+				target[name] = make(map[string]interface{})
+			}
+			source = child
+			target = target[name].(map[string]interface{})
+		}
+	}
+	return result
+}
+
+// Create a strong ETag based on the supplied body
+func etag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// Check to see if the supplied If-None-Match header value matches the ETag. Weak tags are allowed to match
+func etagMatches(header string, tag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Check to see if the supplied If-Match header value matches the ETag. Only strong tags are allowed to match, since
+// the changes must be based on exactly this version. "*" never matches, since it doesn't say what version the
+// changes are based on
+func strongETagMatches(header string, tag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		if strings.TrimSpace(value) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func getContent(repository content.Repository, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/content")
+	if path == "" || path == "/" {
+		path = "/index"
+	}
+
+	if !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	model, err := repository.FindByPath(path)
+	if err != nil {
+		returnNotFound(rw)
+		return
+	}
+
+	document, err := toDocument(newContentResponse(path, model))
+	if err != nil {
+		log.Warnf(r.Context(), "Could not convert %s into a document: %v", path, err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	query := r.URL.Query()
+	for _, field := range splitFields(query.Get("expand")) {
+		expandField(repository, service, user, document, field)
+	}
+	if fields := splitFields(query.Get("fields")); len(fields) > 0 {
+		document = projectFields(document, fields)
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		returnErrorResponse(rw, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// The version is only used as ETag if the response is the entire page, since expanded references can change
+	// without the page itself being changed
+	tag := `"` + model.Version + `"`
+	if query.Get("expand") != "" || query.Get("fields") != "" {
+		tag = etag(body)
+	}
+	rw.Header().Set("ETag", tag)
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(body)
+}
+
+func returnContentConflict(rw http.ResponseWriter, current *ContentResponse, submitted *ContentResponse) {
+	response := &ContentConflictResponse{
+		Code:      http.StatusConflict,
+		Message:   "The page has been changed by someone else",
+		Current:   current,
+		Submitted: submitted,
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusConflict)
+	_, _ = rw.Write(jsonResponse)
+}
+
+// Update, or create, the page at the supplied path. Updates require the If-Match header to contain the version
+// the changes are based on. New pages are created by using "If-None-Match: *" instead.
+func putContent(repository content.Repository, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/content")
+	if path == "" || path == "/" {
+		path = "/index"
+	}
+	if strings.Contains(path, "..") || !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	// "If-Match: *" only says that the page must exist, which is not enough to know if the changes overwrite
+	// changes made by someone else
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	create := r.Header.Get("If-None-Match") == "*"
+	if (ifMatch == "" || ifMatch == "*") && !create {
+		returnErrorResponse(rw, http.StatusPreconditionRequired, "The If-Match header is required")
+		return
+	}
+
+	var body ContentRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	defer r.Body.Close()
+	if err != nil {
+		returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	model := &content.Model{
+		ID:           body.ID,
+		CreatedAt:    body.CreatedAt,
+		View:         body.View,
+		Type:         body.Type,
+		Tags:         body.Tags,
+		Meta:         body.Meta,
+		MetaDefaults: body.MetaDefaults,
+	}
+	if body.Type != "" {
+		fn, ok := repository.GetUnmarshalFuncs()[body.Type]
+		if !ok {
+			returnErrorResponse(rw, http.StatusBadRequest, "Unknown type: "+body.Type)
+			return
+		}
+		if model.Content, err = fn(body.Content); err != nil {
+			returnErrorResponse(rw, http.StatusBadRequest, "Invalid content: "+err.Error())
+			return
+		}
+	}
+
+	existing, err := repository.FindByPath(path)
+	exists := err == nil
+	if create {
+		if exists {
+			returnContentConflict(rw, newContentResponse(path, existing), newContentResponse(path, model))
+			return
+		}
+		// The page might be created by someone else before it's saved
+		model.Version = content.NoVersion
+		if model.CreatedAt.IsZero() {
+			model.CreatedAt = time.Now().UTC()
+		}
+	} else {
+		if !exists {
+			returnNotFound(rw)
+			return
+		}
+		// The header may list multiple versions. The changes are based on the current version if it's one of them
+		if !strongETagMatches(ifMatch, `"`+existing.Version+`"`) {
+			returnContentConflict(rw, newContentResponse(path, existing), newContentResponse(path, model))
+			return
+		}
+		model.Version = existing.Version
+		if model.ID == "" {
+			model.ID = existing.ID
+		}
+		if model.CreatedAt.IsZero() {
+			model.CreatedAt = existing.CreatedAt
+		}
+	}
+
+	saved, err := repository.Save(r.Context(), path, model, user.Name)
+	if err != nil {
+		if returnLocked(rw, err) {
+			return
+		}
+		var conflict *content.ConflictError
+		if errors.As(err, &conflict) {
+			current, _ := repository.FindByPath(path)
+			returnContentConflict(rw, newContentResponse(path, current), newContentResponse(path, model))
+			return
+		}
+		log.Errorf(r.Context(), "Could not save %s: %v", path, err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Could not save: "+path)
+		return
+	}
+
+	rw.Header().Set("ETag", `"`+saved.Version+`"`)
+	returnSuccess(rw, newContentResponse(path, saved))
+}
@@ -0,0 +1,48 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"net/http"
+)
+
+func getDeployments(controller content.Controller, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Admin) {
+		returnForbidden(rw)
+		return
+	}
+
+	deployments, err := controller.History(r.Context())
+	if err != nil {
+		log.Errorf(r.Context(), "Could not read deployment history: %v", err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Could not read deployment history")
+		return
+	}
+	returnSuccess(rw, deployments)
+}
+
+func rollback(controller content.Controller, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Admin) {
+		returnForbidden(rw)
+		return
+	}
+
+	deployment, err := controller.Rollback(r.Context())
+	if err != nil {
+		log.Warnf(r.Context(), "Could not rollback content: %v", err)
+		returnCheckoutError(rw, "previous deployment", err)
+		return
+	}
+	if deployment == nil {
+		returnErrorResponse(rw, http.StatusConflict, "There's no previous deployment to rollback to")
+		return
+	}
+	returnSuccess(rw, &CheckoutResponse{deployment.Commit})
+}
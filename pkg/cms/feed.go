@@ -0,0 +1,22 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/feed"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"net/http"
+)
+
+func getFeed(feeds *feed.Feeds, ctx *RequestContext) {
+	rw := ctx.Response
+	r := ctx.Request
+	document, err := feeds.Find(r.URL.Path)
+	if err != nil {
+		log.Warnf(r.Context(), "Could not generate feed: %v", err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	rw.Header().Set("Content-Type", document.ContentType)
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(document.Body)
+}
@@ -0,0 +1,149 @@
+package cms
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/form"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// The maximum size of a form submission
+const maxFormSize = 1 << 20
+
+type FormSubmissionResponse struct {
+	ID string
+}
+
+// Error returned when a form submission contains invalid values. The errors are keyed by field name
+type FormErrorResponse struct {
+	Code    int
+	Message string
+	Errors  map[string]string
+}
+
+// Check to see if the supplied request wants a json response instead of a redirect
+func isJSONRequest(r *http.Request) bool {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return contentType == "application/json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// Read the submitted values. Both json documents and regular html forms are supported
+func readFormValues(rw http.ResponseWriter, r *http.Request) (map[string]string, error) {
+	r.Body = http.MaxBytesReader(rw, r.Body, maxFormSize)
+	defer r.Body.Close()
+
+	values := make(map[string]string)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "application/json" {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		for key, value := range body {
+			if value != nil {
+				values[key] = fmt.Sprint(value)
+			}
+		}
+		return values, nil
+	}
+
+	var err error
+	if contentType == "multipart/form-data" {
+		err = r.ParseMultipartForm(maxFormSize)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key := range r.PostForm {
+		values[key] = r.PostForm.Get(key)
+	}
+	return values, nil
+}
+
+func submitForm(forms *form.Database, submissions *form.Submissions, id string, ctx *RequestContext) {
+	rw := ctx.Response
+	r := ctx.Request
+
+	definition, err := forms.Find(id)
+	if err != nil {
+		returnNotFound(rw)
+		return
+	}
+
+	values, err := readFormValues(rw, r)
+	if err != nil {
+		log.Warnf(r.Context(), "Could not read submission of form %s: %v", id, err)
+		returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if errors := definition.Validate(values); len(errors) > 0 {
+		response := &FormErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid form values",
+			Errors:  errors,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			panic(err)
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write(jsonResponse)
+		return
+	}
+
+	submission, err := submissions.Add(r.Context(), definition, values)
+	if err != nil {
+		log.Errorf(r.Context(), "Could not save submission of form %s: %v", id, err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Could not save submission")
+		return
+	}
+
+	if definition.SuccessPage != "" && !isJSONRequest(r) {
+		http.Redirect(rw, r, definition.SuccessPage, http.StatusSeeOther)
+		return
+	}
+	returnSuccess(rw, &FormSubmissionResponse{submission.ID})
+}
+
+func getFormSubmissions(forms *form.Database, submissions *form.Submissions, id string, csv bool,
+	ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Admin) {
+		returnForbidden(rw)
+		return
+	}
+
+	definition, err := forms.Find(id)
+	if err != nil {
+		returnNotFound(rw)
+		return
+	}
+
+	result, err := submissions.List(definition.ID)
+	if err != nil {
+		log.Errorf(r.Context(), "Could not list submissions of form %s: %v", id, err)
+		returnErrorResponse(rw, http.StatusInternalServerError, "Could not list submissions")
+		return
+	}
+
+	if csv {
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		rw.Header().Set("Content-Disposition", `attachment; filename="`+definition.ID+`.csv"`)
+		rw.WriteHeader(http.StatusOK)
+		if err := form.WriteCSV(rw, definition, result); err != nil {
+			log.Warnf(r.Context(), "Could not write submissions of form %s: %v", id, err)
+		}
+		return
+	}
+	returnSuccess(rw, result)
+}
@@ -0,0 +1,45 @@
+package cms
+
+import (
+	"encoding/json"
+	"github.com/westcoastcode-se/gocms/pkg/graphql"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"net/http"
+)
+
+func queryGraphQL(service *graphql.ContentService, ctx *RequestContext) {
+	rw := ctx.Response
+	r := ctx.Request
+
+	var request graphql.Request
+	if r.Method == http.MethodPost {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&request)
+		defer r.Body.Close()
+		if err != nil {
+			log.Warnf(r.Context(), "Could not parse GraphQL request: %v", err)
+			returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+			return
+		}
+	} else {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+				return
+			}
+		}
+
+		// Return the schema if no query is supplied
+		if request.Query == "" {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(service.Schema().String()))
+			return
+		}
+	}
+
+	returnSuccess(rw, service.Execute(r.Context(), &request))
+}
@@ -0,0 +1,130 @@
+package cms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The maximum size of a push payload
+const maxGitHookSize = 5 << 20
+
+// The commit sent by git hosts when a branch is deleted
+const deletedCommit = "0000000000000000000000000000000000000000"
+
+// The user name recorded for deployments triggered by a git host
+const gitHookUser = "git-hook"
+
+// The parts of a push payload we care about. GitHub, GitLab and Gitea use the same names for these fields
+type gitPushPayload struct {
+	Ref   string `json:"ref"`
+	After string `json:"after"`
+}
+
+type GitHookResponse struct {
+	// The commit that's being checked out. Empty if the push is ignored
+	Commit string
+	// If the push resulted in a checkout. The checkout is done after the response is sent, which means that the
+	// outcome is only found in the deployment history
+	Deployed bool
+}
+
+// Check to see if the supplied hex encoded signature is the HMAC-SHA256 of the body
+func validSignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Verify that the request is sent by a git host we share the secret with. Will return the name of the event
+// being sent or false if the request could not be verified
+func verifyGitHook(secret string, r *http.Request, body []byte) (string, bool) {
+	if event := r.Header.Get("X-Gitea-Event"); event != "" {
+		return event, validSignature(secret, body, r.Header.Get("X-Gitea-Signature"))
+	}
+	if event := r.Header.Get("X-Gitlab-Event"); event != "" {
+		token := r.Header.Get("X-Gitlab-Token")
+		return event, subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+	if event := r.Header.Get("X-GitHub-Event"); event != "" {
+		signature := r.Header.Get("X-Hub-Signature-256")
+		if !strings.HasPrefix(signature, "sha256=") {
+			return event, false
+		}
+		return event, validSignature(secret, body, signature[len("sha256="):])
+	}
+	return "", false
+}
+
+func receiveGitHook(controller content.Controller, hook config.GitHookConfig, ctx *RequestContext) {
+	rw := ctx.Response
+	r := ctx.Request
+	if hook.Secret == "" {
+		returnNotFound(rw)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxGitHookSize))
+	defer r.Body.Close()
+	if err != nil {
+		returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	event, ok := verifyGitHook(hook.Secret, r, body)
+	if !ok {
+		log.Warnf(r.Context(), "Received a git hook with an invalid signature from %s", getIpAddress(r))
+		returnForbidden(rw)
+		return
+	}
+
+	// Events other than pushes, such as the ping sent by GitHub when a hook is created, are acknowledged
+	if event != "push" && event != "Push Hook" {
+		returnSuccess(rw, &GitHookResponse{})
+		return
+	}
+
+	var payload gitPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		log.Warnf(r.Context(), "Could not parse git hook payload: %v", err)
+		returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if payload.Ref != "refs/heads/"+hook.Branch || payload.After == "" || payload.After == deletedCommit {
+		log.Infof(r.Context(), "Ignoring push to %s", payload.Ref)
+		returnSuccess(rw, &GitHookResponse{})
+		return
+	}
+
+	// Git hosts give up on hooks that are slow to respond, so the checkout is done in the background. It's only
+	// bounded by the timeout of the git commands, since the request is finished long before the checkout
+	log.Infof(r.Context(), "Checking out %s pushed to %s", payload.After, payload.Ref)
+	deployCtx := log.Detach(log.SetUserName(r.Context(), gitHookUser))
+	go func() {
+		if err := controller.Update(deployCtx, payload.After); err != nil {
+			log.Warnf(deployCtx, "Could not checkout %s: %v", payload.After, err)
+		}
+	}()
+
+	jsonResponse, err := json.Marshal(&GitHookResponse{Commit: payload.After, Deployed: true})
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	_, _ = rw.Write(jsonResponse)
+}
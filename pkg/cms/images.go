@@ -0,0 +1,63 @@
+package cms
+
+import (
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/imaging"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Check to see if the supplied uri contains hidden files or directories, such as ".git"
+func isHiddenPath(uri string) bool {
+	for _, part := range strings.Split(uri, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve a resized version of an image. The uri "{prefix}/media/photo.jpg?w=800" is a resized version of the static
+// file "/assets/media/photo.jpg"
+func getImage(resizer *imaging.Resizer, service acl.Service, prefix string, staticPrefix string,
+	ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	if isHiddenPath(rest) {
+		http.NotFound(rw, r)
+		return
+	}
+	uri := path.Join(staticPrefix, path.Clean("/"+rest))
+	if !user.HasRoles(service.GetRoles(uri)) {
+		returnForbidden(rw)
+		return
+	}
+
+	options, err := resizer.ParseOptions(r.URL.Query())
+	if err != nil {
+		returnErrorResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cached, err := resizer.Resize(r.Context(), uri, options)
+	if err != nil {
+		var unsupported *imaging.UnsupportedImageError
+		if os.IsNotExist(err) {
+			http.NotFound(rw, r)
+		} else if errors.As(err, &unsupported) {
+			returnErrorResponse(rw, http.StatusBadRequest, err.Error())
+		} else {
+			log.Errorf(r.Context(), "Could not resize %s: %v", uri, err)
+			returnErrorResponse(rw, http.StatusInternalServerError, "Could not resize image")
+		}
+		return
+	}
+	http.ServeFile(rw, r, cached)
+}
@@ -0,0 +1,114 @@
+package cms
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"net/http"
+	"strings"
+)
+
+// Error returned when a page is locked by another user
+type LockedResponse struct {
+	Code    int
+	Message string
+	Lock    content.Lock
+}
+
+func returnLocked(rw http.ResponseWriter, err error) bool {
+	var locked *content.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	response := &LockedResponse{
+		Code:    http.StatusLocked,
+		Message: locked.Lock.User + " is editing this page",
+		Lock:    locked.Lock,
+	}
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusLocked)
+	_, _ = rw.Write(jsonResponse)
+	return true
+}
+
+// Figure out the path of the page from a lock uri, such as "/api/v1/pages/news/first/lock"
+func lockPath(uri string) string {
+	path := strings.TrimSuffix(strings.TrimPrefix(uri, "/api/v1/pages"), "/lock")
+	if path == "" || path == "/" {
+		path = "/index"
+	}
+	return path
+}
+
+func getLock(locks *content.LockService, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	path := lockPath(ctx.Request.URL.Path)
+	if !user.IsLoggedIn() || !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	lock := locks.Find(path)
+	if lock == nil {
+		returnNotFound(rw)
+		return
+	}
+	returnSuccess(rw, lock)
+}
+
+// Acquire the lock of a page. Editors are expected to call this repeatedly while editing, since locks expire
+// unless they are renewed
+func lockPage(locks *content.LockService, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	path := lockPath(r.URL.Path)
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) || !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	lock, err := locks.Acquire(r.Context(), path, user.Name)
+	if err != nil {
+		if !returnLocked(rw, err) {
+			returnErrorResponse(rw, http.StatusInternalServerError, "Could not lock: "+path)
+		}
+		return
+	}
+	returnSuccess(rw, lock)
+}
+
+// Release the lock of a page. Admins can release locks held by other users by using "?force=true"
+func unlockPage(locks *content.LockService, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	path := lockPath(r.URL.Path)
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) || !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "true"
+	if force && !user.HasRole(security.Admin) {
+		returnForbidden(rw)
+		return
+	}
+
+	if err := locks.Release(r.Context(), path, user.Name, force); err != nil {
+		if !returnLocked(rw, err) {
+			returnErrorResponse(rw, http.StatusInternalServerError, "Could not unlock: "+path)
+		}
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package cms
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/media"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The memory used when parsing an upload. Larger uploads are buffered in temporary files
+const maxUploadMemory = 1 << 20
+
+type MediaUploadResponse struct {
+	// The uploaded file
+	File *media.File
+	// If the same content was uploaded earlier. The earlier uploaded file is returned if this is true
+	Duplicate bool
+}
+
+// Error returned when a file is deleted while pages still reference it
+type MediaInUseResponse struct {
+	Code    int
+	Message string
+	Pages   []string
+}
+
+// Check to see if the supplied error is caused by a request body that's larger than allowed by http.MaxBytesReader
+func isTooLarge(err error) bool {
+	return strings.Contains(err.Error(), "http: request body too large")
+}
+
+func uploadMedia(library *media.Library, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, library.MaxSize+maxUploadMemory)
+	defer r.Body.Close()
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		log.Warnf(r.Context(), "Could not read upload: %v", err)
+		if isTooLarge(err) {
+			returnErrorResponse(rw, http.StatusRequestEntityTooLarge, "The upload is too large")
+		} else {
+			returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	upload, header, err := r.FormFile("file")
+	if err != nil {
+		returnErrorResponse(rw, http.StatusBadRequest, "Missing file")
+		return
+	}
+	defer upload.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(upload, library.MaxSize+1))
+	if err != nil {
+		log.Warnf(r.Context(), "Could not read upload: %v", err)
+		returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	author := content.Author{Name: user.Name, Email: user.Email}
+	file, created, err := library.Upload(r.Context(), author, header.Filename, data, r.FormValue("alt"))
+	if err != nil {
+		var tooLarge *media.TooLargeError
+		var unsupported *media.UnsupportedTypeError
+		if errors.As(err, &tooLarge) {
+			returnErrorResponse(rw, http.StatusRequestEntityTooLarge, err.Error())
+		} else if errors.As(err, &unsupported) {
+			returnErrorResponse(rw, http.StatusUnsupportedMediaType, err.Error())
+		} else {
+			log.Errorf(r.Context(), "Could not save upload %s: %v", header.Filename, err)
+			returnErrorResponse(rw, http.StatusInternalServerError, "Could not save upload")
+		}
+		return
+	}
+	returnSuccess(rw, &MediaUploadResponse{File: file, Duplicate: !created})
+}
+
+func getMedia(library *media.Library, id string, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	if id == "" {
+		returnSuccess(rw, library.List())
+		return
+	}
+
+	file, err := library.Find(id)
+	if err != nil {
+		returnNotFound(rw)
+		return
+	}
+	returnSuccess(rw, file)
+}
+
+func deleteMedia(library *media.Library, id string, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	err := library.Delete(r.Context(), content.Author{Name: user.Name, Email: user.Email}, id)
+	if err != nil {
+		var notFound *media.NotFoundError
+		var inUse *media.InUseError
+		if errors.As(err, &notFound) {
+			returnNotFound(rw)
+		} else if errors.As(err, &inUse) {
+			response := &MediaInUseResponse{
+				Code:    http.StatusConflict,
+				Message: "The file is used by one or more pages",
+				Pages:   inUse.Pages,
+			}
+			jsonResponse, err := json.Marshal(response)
+			if err != nil {
+				panic(err)
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusConflict)
+			_, _ = rw.Write(jsonResponse)
+		} else {
+			log.Errorf(r.Context(), "Could not delete media %s: %v", id, err)
+			returnErrorResponse(rw, http.StatusInternalServerError, "Could not delete media")
+		}
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
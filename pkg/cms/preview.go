@@ -0,0 +1,71 @@
+package cms
+
+import (
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/preview"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Render a page from a snapshot of a git ref. Only users allowed to write content can preview. The uri is
+// "/preview/{ref}/{path}", where refs containing slashes, such as "feature/news", must be escaped:
+// "/preview/feature%2Fnews/news"
+func previewPage(previews *preview.Previews, archives []config.ArchiveConfig, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/preview/")
+	escapedRef := rest
+	path := "/index"
+	if i := strings.Index(rest, "/"); i >= 0 {
+		escapedRef = rest[:i]
+		if rest[i:] != "/" {
+			path = rest[i:]
+		}
+	}
+
+	ref, err := url.PathUnescape(escapedRef)
+	if err != nil || ref == "" {
+		http.NotFound(rw, r)
+		return
+	}
+	path, err = url.PathUnescape(path)
+	if err != nil {
+		http.NotFound(rw, r)
+		return
+	}
+
+	snapshot, err := previews.Find(r.Context(), ref)
+	if err != nil {
+		log.Warnf(r.Context(), "Could not preview %s: %v", ref, err)
+		var gitErr *content.GitError
+		if errors.As(err, &gitErr) {
+			http.NotFound(rw, r)
+			return
+		}
+		http.Error(rw, "Could not preview: "+ref, http.StatusInternalServerError)
+		return
+	}
+	defer previews.Release(r.Context(), snapshot)
+
+	if !acl.IsAccessible(snapshot.ACL, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.Path = path
+	r.URL.RawPath = ""
+	renderPage(snapshot.Repository, snapshot.ACL, snapshot.TemplateRenderers, archives, rw, r)
+}
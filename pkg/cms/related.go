@@ -0,0 +1,52 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultRelatedCount = 5
+
+func getRelated(repository content.Repository, service acl.Service, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/related")
+	if path == "" || path == "/" {
+		path = "/index"
+	}
+
+	if !acl.IsAccessible(service, user, path) {
+		returnForbidden(rw)
+		return
+	}
+
+	if _, err := repository.FindByPath(path); err != nil {
+		returnNotFound(rw)
+		return
+	}
+
+	n := defaultRelatedCount
+	if value := r.URL.Query().Get("n"); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n < 0 {
+			returnErrorResponse(rw, http.StatusBadRequest, "Bad Request")
+			return
+		}
+	}
+
+	response := []*ContentResponse{}
+	for _, related := range repository.Related(path, -1) {
+		if len(response) == n {
+			break
+		}
+		if acl.IsAccessible(service, user, related.Path) {
+			response = append(response, newContentResponse(related.Path, related.Model))
+		}
+	}
+	returnSuccess(rw, response)
+}
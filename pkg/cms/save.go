@@ -0,0 +1,97 @@
+package cms
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"net/http"
+	"strings"
+)
+
+type SaveRequest struct {
+	Message string
+}
+
+type SaveResponse struct {
+	Commit string
+}
+
+// Error returned when content could not be saved
+type SaveErrorResponse struct {
+	Code    int
+	Message string
+	// Why the content could not be saved, for example "conflict" or "push_rejected"
+	Reason string
+	// The files that conflict with changes made by someone else
+	Files []string `json:",omitempty"`
+	// The output written to stderr by the git command that failed
+	Stderr string `json:",omitempty"`
+}
+
+func returnSaveError(rw http.ResponseWriter, err error) {
+	response := &SaveErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: "Could not save content",
+	}
+
+	var saveErr *content.SaveError
+	if errors.As(err, &saveErr) {
+		response.Reason = saveErr.Reason
+		response.Files = saveErr.Files
+		switch saveErr.Reason {
+		case content.NothingToSave:
+			response.Code = http.StatusBadRequest
+			response.Message = "There are no changes to save"
+		case content.SaveConflict:
+			response.Code = http.StatusConflict
+			response.Message = "The changes conflict with changes made by someone else"
+		case content.PushRejected:
+			response.Code = http.StatusConflict
+			response.Message = "The changes were rejected by the remote repository"
+		}
+	}
+
+	var gitErr *content.GitError
+	if errors.As(err, &gitErr) {
+		response.Stderr = gitErr.Stderr
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(response.Code)
+	_, _ = rw.Write(jsonResponse)
+}
+
+func save(controller content.Controller, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	r := ctx.Request
+	if !user.IsLoggedIn() || !user.HasRole(security.Write) {
+		returnForbidden(rw)
+		return
+	}
+
+	var body SaveRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	defer r.Body.Close()
+	if err != nil || strings.TrimSpace(body.Message) == "" {
+		returnErrorResponse(rw, http.StatusBadRequest, "A commit message is required")
+		return
+	}
+
+	commit, err := controller.Save(r.Context(), body.Message, content.Author{Name: user.Name, Email: user.Email})
+	if err != nil {
+		log.Warnf(r.Context(), "Could not save content: %v", err)
+		returnSaveError(rw, err)
+		return
+	}
+
+	log.Infof(r.Context(), "Saved content as %s", commit)
+	returnSuccess(rw, &SaveResponse{commit})
+}
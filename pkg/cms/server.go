@@ -1,15 +1,24 @@
 package cms
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/westcoastcode-se/gocms/pkg/asset"
 	"github.com/westcoastcode-se/gocms/pkg/cache"
 	"github.com/westcoastcode-se/gocms/pkg/config"
 	"github.com/westcoastcode-se/gocms/pkg/content"
 	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/feed"
+	"github.com/westcoastcode-se/gocms/pkg/form"
+	"github.com/westcoastcode-se/gocms/pkg/graphql"
+	"github.com/westcoastcode-se/gocms/pkg/imaging"
 	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/media"
 	. "github.com/westcoastcode-se/gocms/pkg/middleware"
+	"github.com/westcoastcode-se/gocms/pkg/preview"
 	"github.com/westcoastcode-se/gocms/pkg/render"
 	"github.com/westcoastcode-se/gocms/pkg/render/html"
 	"github.com/westcoastcode-se/gocms/pkg/render/html/cached"
@@ -18,8 +27,11 @@ import (
 	"github.com/westcoastcode-se/gocms/pkg/security/acl"
 	"github.com/westcoastcode-se/gocms/pkg/security/auth"
 	"github.com/westcoastcode-se/gocms/pkg/security/jwt"
+	"github.com/westcoastcode-se/gocms/pkg/sitemap"
+	"github.com/westcoastcode-se/gocms/pkg/webhook"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -62,12 +74,50 @@ type Server struct {
 	// Handler for static files
 	FileHandler FileHandler
 
+	// Content hashes of the static files, used for fingerprinted URLs
+	Assets *asset.Pipeline
+
+	// Bundles of stylesheets and scripts
+	Bundles *asset.Bundles
+
 	// Cache used for rendered pages
 	PageCache cache.Pages
 
+	// Encoders used when compressing rendered pages, in the order they are preferred. You can add custom encoders
+	// if you want by:
+	//  server.Encoders = append([]middleware.Encoder{custom.NewBrotliEncoder()}, server.Encoders...)
+	Encoders []Encoder
+
 	// Used for figuring what parts of the web requires what user roles
 	ACL acl.Service
 
+	// Generator for the sitemap.xml documents
+	Sitemap *sitemap.Generator
+
+	// RSS and Atom feeds
+	Feeds *feed.Feeds
+
+	// Forms defined as content
+	Forms *form.Database
+
+	// Store where form submissions are saved
+	FormSubmissions *form.Submissions
+
+	// Dispatcher that sends events to the configured webhooks
+	Webhooks *webhook.Dispatcher
+
+	// Library of uploaded images and other files
+	Media *media.Library
+
+	// Service that resizes images found among the static files
+	Images *imaging.Resizer
+
+	// Previews of git refs. Only available on author instances
+	Previews *preview.Previews
+
+	// Service used for executing GraphQL queries against the content
+	GraphQL *graphql.ContentService
+
 	// Container for template renderers. You can add custom renderers if you want by:
 	//  server.TemplateRenderers.AddFactory(NewCustomTemplateFactory())
 	//
@@ -82,40 +132,129 @@ type Server struct {
 	server http.Server
 }
 
-func (s *Server) ServeTemplate(rw http.ResponseWriter, r *http.Request) {
+// Search for the model to render. Paginated uris, such as "/news/page/2", resolve to the model of the listing
+// page with the page number injected into the request context. Each page is cached separately. The listing page
+// is only found if it paginates a list when it's rendered, which is checked by renderPage
+func findModel(repository content.Repository, service acl.Service, archives []config.ArchiveConfig,
+	r *http.Request) (*content.Model, *http.Request, error) {
 	uri := r.URL.Path
+	user, ok := r.Context().Value(jwt.SessionKey).(*security.User)
+	if !ok {
+		user = security.NotLoggedInUser
+	}
+	viewer := cache.Viewer(r.Context(), user)
+	model, err := findPage(repository, service, viewer, archives, uri)
+	if err != nil {
+		if path, page, ok := content.SplitPageURI(uri); ok && page > 1 {
+			if listing, e := findPage(repository, service, viewer, archives, path); e == nil {
+				ctx := content.SetPageNumber(content.SetPagePath(r.Context(), path), page)
+				ctx = content.TrackPagination(ctx)
+				return listing, r.WithContext(ctx), nil
+			}
+		}
+	}
+	return model, r.WithContext(content.SetPagePath(r.Context(), uri)), err
+}
 
-	ctx := &RequestContext{User: r.Context().Value(jwt.SessionKey).(*security.User), Response: rw, Request: r}
+// Search for the page at the supplied path. Falls back to the configured date-based archives if no page is found.
+// Archives only list the pages that the supplied viewer is allowed to see
+func findPage(repository content.Repository, service acl.Service, viewer *security.User,
+	archives []config.ArchiveConfig, path string) (*content.Model, error) {
+	model, err := repository.FindByPath(path)
+	if err == nil {
+		return model, nil
+	}
 
-	if s.handleBuiltIn(ctx) {
-		return
+	for _, archive := range archives {
+		year, month, ok := content.ParseArchiveURI(archive.Prefix, path)
+		if !ok {
+			continue
+		}
+
+		var visible []*content.SearchResult
+		for _, item := range repository.Search(archive.Type) {
+			if acl.IsAccessible(service, viewer, item.Path) {
+				visible = append(visible, item)
+			}
+		}
+		items := content.FilterArchive(visible, year, month)
+		if len(items) == 0 {
+			break
+		}
+
+		title := strconv.Itoa(year)
+		if month != 0 {
+			title = month.String() + " " + title
+		}
+		return &content.Model{
+			View: archive.View,
+			Type: content.ArchiveType,
+			Meta: content.Meta{Title: title},
+			Content: &content.Archive{
+				Type:   archive.Type,
+				Prefix: archive.Prefix,
+				Year:   year,
+				Month:  month,
+				Items:  items,
+			},
+		}, nil
 	}
-	if s.handleExtended(ctx) {
+	return model, err
+}
+
+// Render the page found at the request uri, using the supplied content and template renderers
+func renderPage(repository content.Repository, service acl.Service, renderers *render.TemplateRenderers,
+	archives []config.ArchiveConfig, rw http.ResponseWriter, r *http.Request) {
+	model, r, pageNotFound := findModel(repository, service, archives, r)
+
+	// Fetch a factory for the template renderer. TODO: Custom view
+	renderFactory, err := renderers.FindFactory("index.html")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		log.LogFromRequest(r).Warn(err.Error())
 		return
 	}
 
-	Cache(s.PageCache, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		model, pageNotFound := s.ContentRepository.FindByPath(uri)
-
-		// Fetch a factory for the template renderer. TODO: Custom view
-		renderFactory, err := s.TemplateRenderers.FindFactory("index.html")
-		if err != nil {
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
-			log.LogFromRequest(r).Warn(err.Error())
+	// Render into a buffer, so that a failed rendering results in a proper http status
+	var buf bytes.Buffer
+	renderer := renderFactory.NewRenderer(r)
+	err = renderer.RenderView(&buf, "index.html", model)
+	if err != nil {
+		var outOfRange *content.PageOutOfRangeError
+		if errors.As(err, &outOfRange) || pageNotFound != nil {
+			http.NotFound(rw, r)
 			return
 		}
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		log.LogFromRequest(r).Warn(err.Error())
+		return
+	}
 
-		// Set http status
-		if pageNotFound != nil {
-			rw.WriteHeader(http.StatusNotFound)
-		}
+	// Pages that don't paginate anything only exist as the first page
+	if content.GetPageNumber(r.Context()) > 1 && !content.IsPaginated(r.Context()) {
+		http.NotFound(rw, r)
+		return
+	}
 
-		renderer := renderFactory.NewRenderer(r)
-		err = renderer.RenderView(rw, "index.html", model)
-		if err != nil {
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
-			log.LogFromRequest(r).Warn(err.Error())
-		}
+	// Set http status
+	if pageNotFound != nil {
+		rw.WriteHeader(http.StatusNotFound)
+	}
+	_, _ = rw.Write(buf.Bytes())
+}
+
+func (s *Server) ServeTemplate(rw http.ResponseWriter, r *http.Request) {
+	ctx := &RequestContext{User: r.Context().Value(jwt.SessionKey).(*security.User), Response: rw, Request: r}
+
+	if s.handleBuiltIn(ctx) {
+		return
+	}
+	if s.handleExtended(ctx) {
+		return
+	}
+
+	Cache(s.PageCache, s.Encoders, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		renderPage(s.ContentRepository, s.ACL, s.TemplateRenderers, s.config.Archives, rw, r)
 	})).ServeHTTP(rw, r)
 }
 
@@ -143,7 +282,7 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	if err == nil && token.Value != "" {
 		user, err = s.Tokenizer.TokenToUser(token.Value)
 		if err != nil {
-			log.LogFromRequest(r).Warnf("User token could not be loaded. Reason %e", err)
+			log.LogFromRequest(r).Warnf("User token could not be loaded. Reason %v", err)
 		}
 	}
 	if user == nil {
@@ -203,6 +342,107 @@ func (s *Server) handleBuiltIn(ctx *RequestContext) bool {
 			}
 			checkout(s.ContentController, ctx)
 			return true
+		} else if uri == "/save" && s.config.Author {
+			if r.Method != http.MethodPost {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			save(s.ContentController, ctx)
+			return true
+		} else if uri == "/deployments" {
+			if r.Method != http.MethodGet {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			getDeployments(s.ContentController, ctx)
+			return true
+		} else if uri == "/deployments/rollback" {
+			if r.Method != http.MethodPost {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			rollback(s.ContentController, ctx)
+			return true
+		} else if uri == "/hooks/git" {
+			if r.Method != http.MethodPost {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			receiveGitHook(s.ContentController, s.config.GitHook, ctx)
+			return true
+		} else if strings.HasPrefix(uri, "/content") {
+			if r.Method == http.MethodGet {
+				getContent(s.ContentRepository, s.ACL, ctx)
+			} else if r.Method == http.MethodPut {
+				putContent(s.ContentRepository, s.ACL, ctx)
+			} else {
+				returnMethodNotAllowed(rw)
+			}
+			return true
+		} else if uri == "/graphql" {
+			if r.Method != http.MethodGet && r.Method != http.MethodPost {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			queryGraphQL(s.GraphQL, ctx)
+			return true
+		} else if strings.HasPrefix(uri, "/related") {
+			if r.Method != http.MethodGet {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			getRelated(s.ContentRepository, s.ACL, ctx)
+			return true
+		} else if strings.HasPrefix(uri, "/forms/") {
+			parts := strings.Split(uri[len("/forms/"):], "/")
+			if len(parts) == 1 {
+				if r.Method != http.MethodPost {
+					returnMethodNotAllowed(rw)
+					return true
+				}
+				submitForm(s.Forms, s.FormSubmissions, parts[0], ctx)
+				return true
+			} else if len(parts) == 2 && (parts[1] == "submissions" || parts[1] == "submissions.csv") {
+				if r.Method != http.MethodGet {
+					returnMethodNotAllowed(rw)
+					return true
+				}
+				getFormSubmissions(s.Forms, s.FormSubmissions, parts[0], parts[1] == "submissions.csv", ctx)
+				return true
+			}
+			returnNotFound(rw)
+			return true
+		} else if uri == "/webhooks/deliveries" {
+			if r.Method != http.MethodGet {
+				returnMethodNotAllowed(rw)
+				return true
+			}
+			getWebhookDeliveries(s.Webhooks, ctx)
+			return true
+		} else if (uri == "/media" || strings.HasPrefix(uri, "/media/")) && s.config.Author {
+			id := strings.TrimPrefix(strings.TrimPrefix(uri, "/media"), "/")
+			if r.Method == http.MethodGet {
+				getMedia(s.Media, id, ctx)
+			} else if r.Method == http.MethodPost && id == "" {
+				uploadMedia(s.Media, ctx)
+			} else if r.Method == http.MethodDelete && id != "" {
+				deleteMedia(s.Media, id, ctx)
+			} else {
+				returnMethodNotAllowed(rw)
+			}
+			return true
+		} else if strings.HasPrefix(uri, "/pages/") && strings.HasSuffix(uri, "/lock") {
+			locks := s.ContentRepository.Locks()
+			if r.Method == http.MethodGet {
+				getLock(locks, s.ACL, ctx)
+			} else if r.Method == http.MethodPost {
+				lockPage(locks, s.ACL, ctx)
+			} else if r.Method == http.MethodDelete {
+				unlockPage(locks, s.ACL, ctx)
+			} else {
+				returnMethodNotAllowed(rw)
+			}
+			return true
 		} else if strings.HasPrefix(uri, "/pages") {
 			if r.Method != http.MethodGet {
 				returnMethodNotAllowed(rw)
@@ -213,7 +453,46 @@ func (s *Server) handleBuiltIn(ctx *RequestContext) bool {
 		}
 	}
 
+	if s.Previews != nil && strings.HasPrefix(uri, "/preview/") {
+		if r.Method != http.MethodGet {
+			returnMethodNotAllowed(rw)
+			return true
+		}
+		previewPage(s.Previews, s.config.Archives, ctx)
+		return true
+	}
+
+	if sitemap.IsSitemap(uri) {
+		if r.Method != http.MethodGet {
+			returnMethodNotAllowed(rw)
+			return true
+		}
+		getSitemap(s.Sitemap, ctx)
+		return true
+	}
+
+	if s.Feeds.IsFeed(uri) {
+		if r.Method != http.MethodGet {
+			returnMethodNotAllowed(rw)
+			return true
+		}
+		getFeed(s.Feeds, ctx)
+		return true
+	}
+
+	if strings.HasPrefix(uri, s.config.Images.URIPrefix+"/") {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			returnMethodNotAllowed(rw)
+			return true
+		}
+		getImage(s.Images, s.ACL, s.config.Images.URIPrefix, s.FileHandler.Prefix, ctx)
+		return true
+	}
+
 	if strings.HasPrefix(uri, s.FileHandler.Prefix) {
+		if serveBundle(s.Bundles, ctx) || serveFingerprinted(s.Assets, s.FileHandler.Handler, ctx) {
+			return true
+		}
 		s.FileHandler.Handler.ServeHTTP(rw, r)
 		return true
 	}
@@ -258,6 +537,16 @@ func Handler(fn http.Handler) RequestHandler {
 	return &wrapper{fn: fn}
 }
 
+// Figure out the URI prefix where resized versions of uploaded media are served. Images are resized from the static
+// files, so media that's not served as a static file can't be resized
+func resizedMediaURIPrefix(config *config.Config) string {
+	static := strings.TrimSuffix(config.StaticURIPrefix, "/")
+	if !strings.HasPrefix(config.Media.URIPrefix, static+"/") {
+		return ""
+	}
+	return strings.TrimSuffix(config.Images.URIPrefix, "/") + strings.TrimPrefix(config.Media.URIPrefix, static)
+}
+
 // Create a new CMS server by using the supplied configuration
 func NewServer(config *config.Config) *Server {
 	bus := event.NewBus()
@@ -278,10 +567,38 @@ func NewServer(config *config.Config) *Server {
 		templateDatabase = cached.NewDatabase(bus, config.ContentDirectory+"/templates")
 	}
 
+	contentRepository.Locks().TTL = time.Second * config.LockTTL
+
+	gitController := content.NewGitController(bus, config.ContentDirectory)
+	gitController.Remote = config.Git.Remote
+	gitController.Branch = config.Git.Branch
+	gitController.Timeout = time.Second * config.Git.Timeout
+	gitController.Paths = append(gitController.Paths, config.Media.Directory, config.Media.DatabasePath)
+	gitController.DeploymentLog = content.NewDeploymentLog(config.DeploymentLogPath)
+	aclService := acl.NewFileBasedACL(bus, config.ACLDatabasePath)
+	sitemapGenerator := sitemap.NewGenerator(bus, contentRepository, aclService,
+		func(ctx context.Context) (map[string]time.Time, error) {
+			return gitController.LastModified(ctx, "pages")
+		},
+		config.Site)
+
+	forms := form.NewDatabase(bus, config.ContentDirectory+"/forms")
+
+	assets := asset.NewPipeline(bus, config.ContentDirectory, config.StaticURIPrefix, !config.Author)
+	bundles := asset.NewBundles(bus, config.ContentDirectory, config.StaticURIPrefix+"/bundles", config.Bundles,
+		!config.Author)
+	images := imaging.NewResizer(config.ContentDirectory, config.StaticURIPrefix, config.Images)
+	library := media.NewLibrary(bus, contentRepository, gitController, config.Media, resizedMediaURIPrefix(config))
+
 	templateRenderers := render.NewTemplateRenderers()
 	templateRenderers.AddFactory(".html", &html.TemplateRendererFactory{
 		ContentRepository: contentRepository,
 		TemplateDatabase:  templateDatabase,
+		ACL:               aclService,
+		Forms:             forms,
+		Images:            images,
+		Assets:            assets,
+		Bundles:           bundles,
 		Config:            *config,
 	})
 
@@ -289,14 +606,25 @@ func NewServer(config *config.Config) *Server {
 		Bus:               bus,
 		SecurityService:   auth.NewLoginService(bus, config.UserDatabasePath),
 		Tokenizer:         jwt.NewAsymmetricTokenizer(config.PublicKeyPath, config.PrivateKeyPath),
-		ContentController: content.NewGitController(bus, config.ContentDirectory),
+		ContentController: gitController,
 		ContentRepository: contentRepository,
 		FileHandler: FileHandler{
-			Prefix:  "/assets",
-			Handler: http.FileServer(NewSecureFileSystem(config.ContentDirectory)),
+			Prefix:  config.StaticURIPrefix,
+			Handler: NewStaticHandler(config.ContentDirectory, config.StaticURIPrefix, assets, config.Static),
 		},
+		Assets:            assets,
+		Bundles:           bundles,
 		PageCache:         pageCache,
-		ACL:               acl.NewFileBasedACL(bus, config.ACLDatabasePath),
+		Encoders:          []Encoder{NewGzipEncoder()},
+		ACL:               aclService,
+		Sitemap:           sitemapGenerator,
+		Feeds:             feed.NewFeeds(bus, contentRepository, aclService, config.Site, config.Feeds),
+		Forms:             forms,
+		FormSubmissions:   form.NewSubmissions(bus, config.FormSubmissionsPath),
+		Webhooks:          webhook.NewDispatcher(bus, config.Webhooks),
+		Media:             library,
+		Images:            images,
+		GraphQL:           graphql.NewContentService(contentRepository, aclService, config.GraphQL),
 		TemplateRenderers: templateRenderers,
 		config:            *config,
 		server: http.Server{
@@ -306,6 +634,10 @@ func NewServer(config *config.Config) *Server {
 			IdleTimeout:  time.Second * config.Server.IdleTimeout,
 		},
 	}
+	if config.Author {
+		result.Previews = preview.NewPreviews(gitController, contentRepository, aclService, *config,
+			config.PreviewDirectory, config.PreviewLimit)
+	}
 	result.server.Handler = result
 	return result
 }
@@ -0,0 +1,22 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/sitemap"
+	"net/http"
+)
+
+func getSitemap(generator *sitemap.Generator, ctx *RequestContext) {
+	rw := ctx.Response
+	r := ctx.Request
+	document, err := generator.Find(r.Context(), r.URL.Path)
+	if err != nil {
+		log.Warnf(r.Context(), "Could not find sitemap: %v", err)
+		http.NotFound(rw, r)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(document)
+}
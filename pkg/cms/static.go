@@ -0,0 +1,169 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/asset"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A precompressed version of a static file, found next to the file itself
+type precompressed struct {
+	encoding string
+	ext      string
+}
+
+// Precompressed versions of static files, in the order they are preferred
+var precompressedFiles = []precompressed{
+	{encoding: "br", ext: ".br"},
+	{encoding: "gzip", ext: ".gz"},
+}
+
+// Handler for static files. Files are served with strong ETags based on their content and precompressed versions,
+// such as "site.css.gz", are served to clients that accept them
+type StaticHandler struct {
+	root         string
+	prefix       string
+	assets       *asset.Pipeline
+	mimeTypes    map[string]string
+	cacheControl []config.CacheControlConfig
+}
+
+// Check to see if the client accepts the supplied content encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		name := strings.TrimSpace(params[0])
+		if name != encoding && name != "*" {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[len("q="):], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Figure out the Cache-Control header of the static file with the supplied uri
+func (s *StaticHandler) findCacheControl(uri string) string {
+	var result config.CacheControlConfig
+	for _, c := range s.cacheControl {
+		if strings.HasPrefix(uri, c.Prefix) && len(c.Prefix) >= len(result.Prefix) {
+			result = c
+		}
+	}
+	return result.Value
+}
+
+// Figure out the content type of the supplied file. Will return an empty string if the content type should be
+// sniffed from the content
+func (s *StaticHandler) contentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if contentType, ok := s.mimeTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+func isRegularFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Serve the static file at the request uri. Directories are forbidden and hidden files, as well as files outside
+// the uri prefix, are never found. The configured Cache-Control header is sent unless the header is already set
+func (s *StaticHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	uri := path.Clean("/" + r.URL.Path)
+	// Paths such as "/assets/../config/users.json" are outside the static files once cleaned
+	if !strings.HasPrefix(uri, s.prefix+"/") || isHiddenPath(uri) {
+		http.NotFound(rw, r)
+		return
+	}
+
+	name := filepath.Join(s.root, filepath.FromSlash(uri))
+	info, err := os.Stat(name)
+	if err != nil {
+		if os.IsPermission(err) {
+			http.Error(rw, "403 Forbidden", http.StatusForbidden)
+		} else {
+			http.NotFound(rw, r)
+		}
+		return
+	}
+	if info.IsDir() {
+		http.Error(rw, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+
+	contentType := s.contentType(name)
+	encoding := ""
+	served := name
+	for _, p := range precompressedFiles {
+		if !isRegularFile(name + p.ext) {
+			continue
+		}
+		rw.Header().Set("Vary", "Accept-Encoding")
+		if encoding == "" && contentType != "" && acceptsEncoding(r, p.encoding) {
+			encoding = p.encoding
+			served = name + p.ext
+		}
+	}
+
+	f, err := os.Open(served)
+	if err != nil {
+		http.NotFound(rw, r)
+		return
+	}
+	defer f.Close()
+
+	if hash, ok := s.assets.Hash(uri); ok {
+		etag := hash
+		if encoding != "" {
+			etag += "-" + encoding
+		}
+		rw.Header().Set("ETag", `"`+etag+`"`)
+	}
+	if encoding != "" {
+		rw.Header().Set("Content-Encoding", encoding)
+	}
+	if contentType != "" {
+		rw.Header().Set("Content-Type", contentType)
+	}
+	if rw.Header().Get("Cache-Control") == "" {
+		if cacheControl := s.findCacheControl(uri); cacheControl != "" {
+			rw.Header().Set("Cache-Control", cacheControl)
+		}
+	}
+	http.ServeContent(rw, r, info.Name(), info.ModTime(), f)
+}
+
+// Create a new handler for static files found in the supplied content directory and served with the supplied uri
+// prefix, for example "/assets". The content hashes of the asset pipeline are used as ETags
+func NewStaticHandler(contentDirectory string, prefix string, assets *asset.Pipeline,
+	config config.StaticConfig) *StaticHandler {
+	mimeTypes := make(map[string]string)
+	for ext, contentType := range config.MimeTypes {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		mimeTypes[strings.ToLower(ext)] = contentType
+	}
+	return &StaticHandler{
+		root:         contentDirectory,
+		prefix:       strings.TrimSuffix(prefix, "/"),
+		assets:       assets,
+		mimeTypes:    mimeTypes,
+		cacheControl: config.CacheControl,
+	}
+}
@@ -0,0 +1,17 @@
+package cms
+
+import (
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/webhook"
+)
+
+func getWebhookDeliveries(dispatcher *webhook.Dispatcher, ctx *RequestContext) {
+	user := ctx.User
+	rw := ctx.Response
+	if !user.IsLoggedIn() || !user.HasRole(security.Admin) {
+		returnForbidden(rw)
+		return
+	}
+
+	returnSuccess(rw, dispatcher.Deliveries())
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"flag"
 	"io/ioutil"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -14,8 +16,183 @@ type ServerConfig struct {
 	IdleTimeout  time.Duration
 }
 
+// Site-wide settings. The metadata values are used as defaults for pages that don't specify them
+type SiteConfig struct {
+	// The name of the site
+	Name string
+	// The public base URL of the site, for example "https://www.example.com". Used when creating absolute URLs
+	BaseURL string
+	// Default title
+	Title string
+	// Default description
+	Description string
+	// Default directives for search engine robots
+	Robots string
+	// Default image used by OpenGraph and Twitter cards
+	Image string
+}
+
+// Create an absolute URL based on the supplied path. URLs that are already absolute are returned as-is
+func (s SiteConfig) AbsoluteURL(path string) string {
+	if path == "" || strings.Contains(path, "://") {
+		return path
+	}
+	if path == "/index" {
+		path = "/"
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + path
+}
+
+// Mapping between the fields of a page and the fields of a feed entry. Fields are searched for in the page model
+// first and then in the actual content. Nested fields are separated with a dot, for example "Meta.Title"
+type FeedFieldsConfig struct {
+	Title   string
+	Summary string
+	Content string
+	Date    string
+}
+
+// Configuration for a RSS or Atom feed
+type FeedConfig struct {
+	// The URI where the feed is served, for example "/news/feed.xml"
+	Path string
+	// The format of the feed. Either "rss" or "atom"
+	Format string
+	// The title of the feed
+	Title string
+	// A short description of the feed
+	Description string
+	// Only include pages of this content type, for example "models.News"
+	Type string
+	// Only include pages with this path prefix, for example "/news/"
+	Prefix string
+	// The maximum number of entries in the feed
+	Limit int
+	// How the fields of a page are mapped to a feed entry
+	Fields FeedFieldsConfig
+}
+
+// Configuration for a date-based archive of a content type. Archives are served at "{Prefix}/{year}/" and
+// "{Prefix}/{year}/{month}/", for example "/news/2020/05/"
+type ArchiveConfig struct {
+	// The path prefix of the archive, for example "/news"
+	Prefix string
+	// The content type in the archive, for example "models.News"
+	Type string
+	// The view used when rendering an archive
+	View string
+}
+
+// Configuration for an endpoint that receives events as webhooks
+type WebhookConfig struct {
+	// The URL the events are posted to
+	URL string
+	// Secret used when signing the payload. The signature is sent in the "X-Gocms-Signature" header
+	Secret string
+	// The names of the events sent to the endpoint, for example "checkout" or "page.saved". All events are
+	// sent if no event names are supplied
+	Events []string
+}
+
+// Configuration for the endpoint that receives push events from a git host, such as GitHub, GitLab or Gitea
+type GitHookConfig struct {
+	// The secret shared with the git host. The endpoint is disabled if no secret is configured
+	Secret string
+	// Only pushes to this branch are checked out
+	Branch string
+}
+
+// Configuration for the git repository where the content is located
+type GitConfig struct {
+	// The remote that content is fetched from and pushed to
+	Remote string
+	// The branch that content is fetched from and pushed to
+	Branch string
+	// The maximum time, in seconds, a git command is allowed to run
+	Timeout time.Duration
+}
+
+// Configuration for the media library where editors upload images and other files
+type MediaConfig struct {
+	// Directory, relative to the content directory, where uploaded files are saved. Should be located in the
+	// directory where static files are served
+	Directory string
+	// The URI prefix where uploaded files are served
+	URIPrefix string
+	// Path, relative to the content directory, to the database containing the metadata of all uploaded files
+	DatabasePath string
+	// The maximum size of an uploaded file in bytes
+	MaxSize int64
+}
+
+// A size images are allowed to be resized to. Either the width or the height can be zero, which means that
+// it's calculated from the aspect ratio of the image
+type ImageSizeConfig struct {
+	Width  int
+	Height int
+}
+
+// A named set of widths used by responsive images, for example "hero"
+type ImagePresetConfig struct {
+	// The widths of the resized images an image is available in. These widths are always allowed
+	Widths []int
+	// The value of the sizes attribute, for example "(max-width: 800px) 100vw, 800px"
+	Sizes string
+}
+
+// Configuration for resizing of images
+type ImageConfig struct {
+	// The URI prefix where resized images are served, for example "/assets/img". The rest of the URI is the path
+	// of the image below the static files, which means that "/assets/img/media/photo.jpg?w=800" is a resized
+	// version of "/assets/media/photo.jpg"
+	URIPrefix string
+	// Directory where resized images are cached
+	CacheDirectory string
+	// The quality, between 1 and 100, used when encoding JPEG images
+	Quality int
+	// The sizes images are allowed to be resized to. Other sizes are rejected, so that the server can't be
+	// overloaded by requests for an unlimited number of sizes
+	Sizes []ImageSizeConfig
+	// Named presets used by responsive images
+	Presets map[string]ImagePresetConfig
+}
+
+// Configuration for a bundle of stylesheets or scripts. The files are concatenated and minified. The type of the
+// bundle is figured out from the name of the bundle, for example "site.css" or "app.js"
+type BundleConfig struct {
+	// The URIs of the static files in the bundle, for example "/assets/css/reset.css"
+	Files []string
+}
+
+// The Cache-Control header sent for static files with a specific path prefix
+type CacheControlConfig struct {
+	// The path prefix, for example "/assets/media/". The longest matching prefix is used
+	Prefix string
+	// The value of the Cache-Control header, for example "public, max-age=3600"
+	Value string
+}
+
+// Configuration for how static files are served
+type StaticConfig struct {
+	// Content types used for specific file extensions, for example ".webmanifest": "application/manifest+json".
+	// Overrides the content types known by the system
+	MimeTypes map[string]string
+	// The Cache-Control headers sent for static files. Defaults to caching static files for five minutes and media
+	// for a day if not set
+	CacheControl []CacheControlConfig
+}
+
+// Limits applied to GraphQL queries, so that a single query can't overload the server
+type GraphQLConfig struct {
+	// The maximum number of nested fields in a query
+	MaxDepth int
+	// The maximum number of fields in a query, after all fragments are spread
+	MaxComplexity int
+}
+
 type Config struct {
 	Server            ServerConfig
+	Site              SiteConfig
 	Author            bool
 	PublicKeyPath     string
 	PrivateKeyPath    string
@@ -24,12 +201,37 @@ type Config struct {
 	CacheDatabasePath string
 	ContentDirectory  string
 	StaticURIPrefix   string
+	Feeds             []FeedConfig
+	Archives          []ArchiveConfig
+	Webhooks          []WebhookConfig
+	Git               GitConfig
+	GitHook           GitHookConfig
+	Media             MediaConfig
+	Images            ImageConfig
+	Bundles           map[string]BundleConfig
+	Static            StaticConfig
+	GraphQL           GraphQLConfig
+
+	// Directory where form submissions are saved. Kept outside of the content directory, since submissions
+	// are not content
+	FormSubmissionsPath string
+
+	// Path to the file where all deployments of content are recorded
+	DeploymentLogPath string
+
+	// The time, in seconds, a page is locked for editing unless the lock is renewed
+	LockTTL time.Duration
+
+	// Directory where git worktrees used when previewing refs are created
+	PreviewDirectory string
+	// The maximum number of refs that can be previewed at the same time
+	PreviewLimit int
 }
 
 func GetConfig() *Config {
 	var configPath string
 	flag.StringVar(&configPath, "config-path", "", "Path to the a file where configuration can be found")
-	config := loadConfigFromPath(configPath)
+	config := loadConfigFromPath(findConfigPath(os.Args[1:]))
 
 	flag.StringVar(&config.Server.ListenAddr, "listen-addr", config.Server.ListenAddr, "Address where we listen for incoming requests")
 	flag.BoolVar(&config.Author, "author", config.Author, "If this instance allows for authoring of content")
@@ -40,11 +242,49 @@ func GetConfig() *Config {
 	flag.StringVar(&config.CacheDatabasePath, "cache-db-path", config.CacheDatabasePath, "Path to a database containing the access control list")
 	flag.StringVar(&config.ContentDirectory, "content-path", config.ContentDirectory, "Path to where content can be found")
 	flag.StringVar(&config.StaticURIPrefix, "static-uri-prefix", config.StaticURIPrefix, "URI prefix for")
+	flag.StringVar(&config.FormSubmissionsPath, "form-submissions-path", config.FormSubmissionsPath, "Path to where form submissions are saved")
+	flag.StringVar(&config.DeploymentLogPath, "deployment-log-path", config.DeploymentLogPath, "Path to where deployments are recorded")
+	flag.StringVar(&config.PreviewDirectory, "preview-path", config.PreviewDirectory, "Path to where previews of git refs are checked out")
+	flag.StringVar(&config.Site.BaseURL, "base-url", config.Site.BaseURL, "The public base URL of the site")
 	flag.Parse()
 
+	if config.Static.CacheControl == nil {
+		config.Static.CacheControl = defaultCacheControl(config)
+	}
 	return config
 }
 
+// Create the Cache-Control headers used for static files when none are configured. Uploaded media is rarely changed,
+// so it's cached longer than other static files. The prefixes are taken from the configuration, since they can be
+// changed both in the configuration file and with flags
+func defaultCacheControl(config *Config) []CacheControlConfig {
+	return []CacheControlConfig{
+		{Prefix: strings.TrimSuffix(config.StaticURIPrefix, "/") + "/", Value: "public, max-age=300"},
+		{Prefix: strings.TrimSuffix(config.Media.URIPrefix, "/") + "/", Value: "public, max-age=86400"},
+	}
+}
+
+// Search for the config path among the supplied command line arguments. The configuration file has to be loaded
+// before the flags are parsed, since the flags override the values found in the file
+func findConfigPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if strings.HasPrefix(name, "config-path=") {
+			return name[len("config-path="):]
+		}
+		if name == "config-path" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
 func loadConfigFromPath(path string) *Config {
 	config := &Config{
 		Server: ServerConfig{
@@ -61,6 +301,48 @@ func loadConfigFromPath(path string) *Config {
 		CacheDatabasePath: "content/config/cache.json",
 		ContentDirectory:  "content",
 		StaticURIPrefix:   "/assets",
+		Site: SiteConfig{
+			Name:    "Example",
+			BaseURL: "http://127.0.0.1:8080",
+			Title:   "Example",
+			Robots:  "index,follow",
+		},
+		Git: GitConfig{
+			Remote:  "origin",
+			Branch:  "main",
+			Timeout: 60,
+		},
+		GitHook: GitHookConfig{
+			Branch: "main",
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      10,
+			MaxComplexity: 500,
+		},
+		Media: MediaConfig{
+			Directory:    "assets/media",
+			URIPrefix:    "/assets/media",
+			DatabasePath: "config/media.json",
+			MaxSize:      10 << 20,
+		},
+		Images: ImageConfig{
+			URIPrefix:      "/assets/img",
+			CacheDirectory: "data/images",
+			Quality:        85,
+			Sizes: []ImageSizeConfig{
+				{Width: 320}, {Width: 640}, {Width: 1024}, {Width: 1280}, {Width: 1920},
+				{Width: 800, Height: 600}, {Width: 150, Height: 150},
+			},
+			Presets: map[string]ImagePresetConfig{
+				"hero":    {Widths: []int{640, 1024, 1280, 1920}, Sizes: "100vw"},
+				"content": {Widths: []int{320, 640, 1024}, Sizes: "(max-width: 1024px) 100vw, 1024px"},
+			},
+		},
+		FormSubmissionsPath: "data/forms",
+		DeploymentLogPath:   "data/deployments.jsonl",
+		LockTTL:             120,
+		PreviewDirectory:    "data/previews",
+		PreviewLimit:        5,
 	}
 
 	if len(path) > 0 {
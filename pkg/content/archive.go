@@ -0,0 +1,134 @@
+package content
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The model type of pages generated for date-based archives
+const ArchiveType = "gocms.Archive"
+
+// The content of a page generated for a date-based archive
+type Archive struct {
+	// The content type in the archive, for example "models.News"
+	Type string
+	// The path prefix of the archive, for example "/news"
+	Prefix string
+	// The year of the archive
+	Year int
+	// The month of the archive. Zero if the archive contains the entire year
+	Month time.Month
+	// All pages created during the archived period, newest first
+	Items []*SearchResult
+}
+
+// Number of pages created during a specific month
+type ArchiveMonth struct {
+	Month time.Month
+	Count int
+	URL   string
+}
+
+// Number of pages created during a specific year
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	URL    string
+	Months []*ArchiveMonth
+}
+
+// Group the supplied items into year and month buckets, based on when the items are created. The newest year
+// and month is sorted first.
+func BuildArchives(items []*SearchResult, prefix string) []*ArchiveYear {
+	years := make(map[int]*ArchiveYear)
+	months := make(map[string]*ArchiveMonth)
+	for _, item := range items {
+		createdAt := item.Model.CreatedAt
+		if createdAt.IsZero() {
+			continue
+		}
+
+		year, ok := years[createdAt.Year()]
+		if !ok {
+			year = &ArchiveYear{Year: createdAt.Year(), URL: ArchiveURI(prefix, createdAt.Year(), 0)}
+			years[year.Year] = year
+		}
+		year.Count++
+
+		key := createdAt.Format("2006-01")
+		month, ok := months[key]
+		if !ok {
+			month = &ArchiveMonth{Month: createdAt.Month(), URL: ArchiveURI(prefix, year.Year, createdAt.Month())}
+			months[key] = month
+			year.Months = append(year.Months, month)
+		}
+		month.Count++
+	}
+
+	var result []*ArchiveYear
+	for _, year := range years {
+		sort.Slice(year.Months, func(i, j int) bool {
+			return year.Months[i].Month > year.Months[j].Month
+		})
+		result = append(result, year)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Year > result[j].Year
+	})
+	return result
+}
+
+// Fetch all items created during the supplied year and month, newest first. The month is ignored if it's zero
+func FilterArchive(items []*SearchResult, year int, month time.Month) []*SearchResult {
+	var result []*SearchResult
+	for _, item := range items {
+		createdAt := item.Model.CreatedAt
+		if createdAt.Year() == year && (month == 0 || createdAt.Month() == month) {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[j].Model.CreatedAt.Before(result[i].Model.CreatedAt)
+	})
+	return result
+}
+
+// Create the URI of an archive. For example: "/news/2020/" or "/news/2020/05/"
+func ArchiveURI(prefix string, year int, month time.Month) string {
+	uri := strings.TrimSuffix(prefix, "/") + "/" + strconv.Itoa(year) + "/"
+	if month != 0 {
+		uri += fmt.Sprintf("%02d/", int(month))
+	}
+	return uri
+}
+
+// Parse an archive uri, such as "/news/2020/05/", into a year and a month. The month is zero if the uri refers
+// to an entire year. Will return false if the uri isn't an archive uri with the supplied prefix.
+func ParseArchiveURI(prefix string, uri string) (int, time.Month, bool) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	if !strings.HasPrefix(uri, prefix) {
+		return 0, 0, false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(uri[len(prefix):], "/"), "/")
+	if len(parts) > 2 || len(parts[0]) != 4 {
+		return 0, 0, false
+	}
+
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year < 1 {
+		return 0, 0, false
+	}
+
+	var month int
+	if len(parts) == 2 {
+		month, err = strconv.Atoi(parts[1])
+		if err != nil || len(parts[1]) != 2 || month < 1 || month > 12 {
+			return 0, 0, false
+		}
+	}
+	return year, time.Month(month), true
+}
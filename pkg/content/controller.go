@@ -2,11 +2,25 @@ package content
 
 import "context"
 
+// The author of changes made to the content
+type Author struct {
+	Name  string
+	Email string
+}
+
 // Service that's responsible for all the content managed by the cms
 type Controller interface {
 	// Update the content managed by this controller
 	Update(ctx context.Context, commit string) error
 
-	// Save the content managed by this controller
-	Save(ctx context.Context, message string) error
+	// Save the content managed by this controller. The changes are attributed to the supplied author. Will return
+	// the commit the content is saved as, or a SaveError if the content could not be saved
+	Save(ctx context.Context, message string, author Author) (string, error)
+
+	// Fetch all deployments of content, newest first
+	History(ctx context.Context) ([]*Deployment, error)
+
+	// Checkout the content deployed before the current deployment. Will return the deployment that's been rolled
+	// back to, or nil if there's no previous deployment
+	Rollback(ctx context.Context) (*Deployment, error)
 }
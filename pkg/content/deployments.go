@@ -0,0 +1,133 @@
+package content
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcome of a deployment
+const (
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+)
+
+// A checkout of content
+type Deployment struct {
+	// The ref that was requested, for example a branch name or a commit
+	Ref string
+	// The commit the ref resolved to. Empty if the ref could not be resolved
+	Commit string
+	// The name of the user that requested the deployment
+	User string
+	// When the deployment happened
+	CreatedAt time.Time
+	// The outcome of the deployment. Either "succeeded" or "failed"
+	Outcome string
+	// Why the deployment failed
+	Error string
+	// The commit that was rolled back, if the deployment is a rollback
+	RolledBackFrom string `json:",omitempty"`
+}
+
+// Log of all deployments. The log is persisted as one json record per line
+type DeploymentLog struct {
+	path string
+	mux  sync.Mutex
+}
+
+// Add the supplied deployment to the log
+func (d *DeploymentLog) Add(deployment *Deployment) error {
+	bytes, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(bytes, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Fetch all deployments, newest first
+func (d *DeploymentLog) List() ([]*Deployment, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	file, err := os.Open(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Deployment{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	result := []*Deployment{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var deployment Deployment
+		if err := json.Unmarshal(line, &deployment); err != nil {
+			return nil, err
+		}
+		result = append(result, &deployment)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
+
+// Search for the current deployment and the successful deployment made before it. Commits that have been rolled
+// back are skipped, so that consecutive rollbacks keep moving backwards. The previous deployment is nil if there's
+// no such deployment
+func (d *DeploymentLog) Previous() (*Deployment, *Deployment, error) {
+	deployments, err := d.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var current *Deployment
+	rolledBack := make(map[string]bool)
+	for _, deployment := range deployments {
+		if deployment.Outcome != DeploymentSucceeded || deployment.Commit == "" {
+			continue
+		}
+		if deployment.RolledBackFrom != "" {
+			rolledBack[deployment.RolledBackFrom] = true
+		}
+		if current == nil {
+			current = deployment
+		} else if deployment.Commit != current.Commit && !rolledBack[deployment.Commit] {
+			return current, deployment, nil
+		}
+	}
+	return current, nil, nil
+}
+
+// Create a new deployment log persisted at the supplied path
+func NewDeploymentLog(path string) *DeploymentLog {
+	return &DeploymentLog{path: path, mux: sync.Mutex{}}
+}
@@ -1,5 +1,13 @@
 package content
 
+import (
+	"errors"
+	"strings"
+)
+
+// Error raised when a ref, such as a branch name or a commit, is not allowed to be passed to git
+var ErrInvalidRef = errors.New("invalid ref")
+
 // Error raised when a model at a specific path is not found. This normally results in a HTTP 404.
 type NotFoundError struct {
 	message string
@@ -13,3 +21,80 @@ func (p *NotFoundError) Error() string {
 func NewNotFoundError(path string) *NotFoundError {
 	return &NotFoundError{message: "could not find: " + path}
 }
+
+// Error raised when a git command fails. Contains the output written to stderr by git
+type GitError struct {
+	// The arguments passed to git
+	Args []string
+	// The output written to stderr
+	Stderr string
+	// The underlying error
+	Err error
+}
+
+func (g *GitError) Error() string {
+	message := "git " + strings.Join(g.Args, " ") + " failed: " + g.Err.Error()
+	if g.Stderr != "" {
+		message += ": " + g.Stderr
+	}
+	return message
+}
+
+func (g *GitError) Unwrap() error {
+	return g.Err
+}
+
+// Reasons why content could not be saved
+const (
+	// There are no changes to save
+	NothingToSave = "nothing_to_save"
+	// The changes conflict with changes made by someone else
+	SaveConflict = "conflict"
+	// The remote rejected the changes
+	PushRejected = "push_rejected"
+)
+
+// Error raised when content could not be saved
+type SaveError struct {
+	// Why the content could not be saved, for example "conflict"
+	Reason string
+	// The files that conflict with changes made by someone else
+	Files []string
+	// The underlying error
+	Err error
+}
+
+func (s *SaveError) Error() string {
+	message := "could not save content: " + s.Reason
+	if s.Err != nil {
+		message += ": " + s.Err.Error()
+	}
+	return message
+}
+
+func (s *SaveError) Unwrap() error {
+	return s.Err
+}
+
+// Error raised when a model is saved based on a version that's no longer the latest version
+type ConflictError struct {
+	// The path of the model
+	Path string
+	// The version the changes are based on. Empty if the model was not expected to exist
+	Expected string
+	// The latest version. Empty if the model no longer exists
+	Actual string
+}
+
+func (c *ConflictError) Error() string {
+	return "conflicting changes to " + c.Path + ": expected version " + c.Expected + " but found " + c.Actual
+}
+
+// Error raised when a page is locked by another user
+type LockedError struct {
+	Lock Lock
+}
+
+func (l *LockedError) Error() string {
+	return l.Lock.Path + " is locked by " + l.Lock.User
+}
@@ -0,0 +1,52 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Fetch the value of a field using its name. The field is first searched for in the model itself and then in the
+// actual content. Nested fields are separated with a dot, for example "Meta.Title". Will return nil if the
+// field is not found.
+func (m *Model) Field(name string) interface{} {
+	parts := strings.Split(name, ".")
+	if value, ok := lookupField(reflect.ValueOf(m), parts); ok {
+		return value
+	}
+	if value, ok := lookupField(reflect.ValueOf(m.Content), parts); ok {
+		return value
+	}
+	return nil
+}
+
+func lookupField(value reflect.Value, parts []string) (interface{}, bool) {
+	for _, part := range parts {
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, false
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Struct:
+			value = value.FieldByName(part)
+		case reflect.Map:
+			if value.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			value = value.MapIndex(reflect.ValueOf(part))
+		default:
+			return nil, false
+		}
+
+		if !value.IsValid() {
+			return nil, false
+		}
+	}
+
+	if !value.CanInterface() {
+		return nil, false
+	}
+	return value.Interface(), true
+}
@@ -1,77 +1,489 @@
 package content
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
 type GitController struct {
-	bus      *event.Bus
+	bus *event.Bus
+	// Serializes the git commands. The lock is never held while listeners are notified, since listeners reload the
+	// content and might call the controller
+	mux      sync.Mutex
 	RootPath string
+	// The remote that content is fetched from and pushed to
+	Remote string
+	// The branch that content is fetched from and pushed to
+	Branch string
+	// The maximum time a git command is allowed to run. No timeout is used if zero
+	Timeout time.Duration
+	// Paths, relative to the root path, that are committed when content is saved. Changes made to other files,
+	// such as files written by the server itself, are never committed
+	Paths []string
+	// Log where all checkouts are recorded. Checkouts are not recorded if no log is set
+	DeploymentLog *DeploymentLog
 }
 
+// Refs are passed as arguments to git, so they are not allowed to look like options
+var validRef = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_./-]*$`)
+
+// Check to see if the supplied ref, such as a branch name or a commit, is safe to pass to git
+func isValidRef(ref string) bool {
+	return validRef.MatchString(ref) && !strings.Contains(ref, "..")
+}
+
+// Git commands that change the repository or the work tree. They are never cancelled, other than by the timeout,
+// since a command that's killed halfway can leave locks, such as ".git/index.lock", and a partially updated work
+// tree behind
+var mutatingCommands = map[string]bool{
+	"add": true, "checkout": true, "commit": true, "fetch": true, "push": true, "rebase": true, "worktree": true,
+}
+
+// Figure out the name of the git command in the supplied arguments. Configuration passed with "-c" is skipped
+func commandName(args []string) string {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-c" {
+			i++
+		} else {
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// Run git with the supplied arguments. Will return a GitError, containing the output written to stderr, if git
+// fails or if the command times out
+func (g *GitController) run(ctx context.Context, args ...string) ([]byte, error) {
+	if mutatingCommands[commandName(args)] {
+		ctx = log.Detach(ctx)
+	}
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.RootPath
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	log.Debugf(ctx, "Running git %s", strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %v", g.Timeout)
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, &GitError{Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+// Fetch the latest changes from the remote and checkout the supplied commit. The head of the configured branch is
+// checked out if no commit is supplied
 func (g *GitController) Update(ctx context.Context, commit string) error {
-	err := g.Pull()
+	g.mux.Lock()
+	var deployment *Deployment
+	err := g.fetch(ctx)
 	if err != nil {
-		return err
+		// The deployment failed even though nothing was checked out
+		deployment = g.newDeployment(ctx, commit, "")
+	} else {
+		deployment, err = g.deploy(ctx, commit, "")
 	}
+	g.mux.Unlock()
 
-	return g.Checkout(ctx, commit)
+	return g.finishDeploy(ctx, deployment, err)
 }
 
-func (g *GitController) Save(ctx context.Context, message string) error {
-	err := g.Commit(message)
+// Commit all changes made to the content paths and push them to the configured branch. Listeners are notified
+// about the push, and about changes made by others if our changes had to be put on top of them
+func (g *GitController) Save(ctx context.Context, message string, author Author) (string, error) {
+	g.mux.Lock()
+	commit, rebased, err := g.save(ctx, message, author)
+	g.mux.Unlock()
+
+	if rebased {
+		// Changes made by others are now part of the content
+		if e := g.bus.NotifyAll(ctx, &event.Checkout{Commit: "HEAD"}); e != nil && err == nil {
+			err = e
+		}
+	}
 	if err != nil {
+		return "", err
+	}
+	if err := g.bus.NotifyAll(ctx, &event.Push{}); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// Commit and push all changes made to the content paths. The lock must be held. Will return true if our changes
+// were put on top of changes made by others
+func (g *GitController) save(ctx context.Context, message string, author Author) (string, bool, error) {
+	paths := g.contentPaths(ctx)
+	changed := false
+	if len(paths) > 0 {
+		if _, err := g.run(ctx, append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+			return "", false, err
+		}
+		_, err := g.run(ctx, append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...)
+		changed = err != nil
+	}
+	if changed {
+		if err := g.commit(ctx, message, author, paths...); err != nil {
+			return "", false, err
+		}
+	} else if !g.hasUnpushedCommits(ctx) {
+		// Commits left from an earlier save, that could not be pushed, are pushed even if nothing is changed
+		return "", false, &SaveError{Reason: NothingToSave}
+	}
+
+	rebased := false
+	err := g.push(ctx)
+	var gitErr *GitError
+	if errors.As(err, &gitErr) && isRejected(gitErr.Stderr) {
+		// Someone else has pushed changes. Try to put our changes on top of them before giving up
+		log.Infof(ctx, "Push was rejected. Rebasing on top of %s/%s", g.Remote, g.Branch)
+		if err := g.rebase(ctx, author); err != nil {
+			return "", false, err
+		}
+		rebased = true
+		if err = g.push(ctx); errors.As(err, &gitErr) && isRejected(gitErr.Stderr) {
+			return "", rebased, &SaveError{Reason: PushRejected, Err: err}
+		}
+	}
+	if err != nil {
+		return "", rebased, err
+	}
+
+	out, err := g.run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", rebased, err
+	}
+	return strings.TrimSpace(string(out)), rebased, nil
+}
+
+// Find the content paths that can be staged. Git refuses paths that don't match any files, so paths that are
+// neither found on disk nor known by git are skipped
+func (g *GitController) contentPaths(ctx context.Context) []string {
+	var result []string
+	for _, p := range g.Paths {
+		if _, err := os.Stat(filepath.Join(g.RootPath, p)); err == nil {
+			result = append(result, p)
+		} else if out, err := g.run(ctx, "ls-files", "--", p); err == nil && len(out) > 0 {
+			// Removed paths are staged as removed
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Check to see if there are commits that are not pushed to the configured branch
+func (g *GitController) hasUnpushedCommits(ctx context.Context) bool {
+	out, err := g.run(ctx, "rev-list", "--count", g.Remote+"/"+g.Branch+"..HEAD")
+	return err == nil && strings.TrimSpace(string(out)) != "0"
+}
+
+// Check to see if the supplied stderr output of a push is caused by the remote having changes we don't have
+func isRejected(stderr string) bool {
+	return strings.Contains(stderr, "[rejected]") || strings.Contains(stderr, "non-fast-forward") ||
+		strings.Contains(stderr, "fetch first")
+}
+
+// Fetch the configured branch and put our commits on top of it. A SaveError is returned if the changes conflict,
+// in which case the rebase is aborted
+func (g *GitController) rebase(ctx context.Context, author Author) error {
+	if err := g.fetch(ctx); err != nil {
 		return err
 	}
 
-	return g.Push(ctx)
+	args := append(identity(author), "rebase", g.Remote+"/"+g.Branch)
+	if _, err := g.run(ctx, args...); err != nil {
+		var files []string
+		if out, e := g.run(ctx, "diff", "--name-only", "--diff-filter=U"); e == nil {
+			files = strings.Fields(string(out))
+		}
+		if _, e := g.run(ctx, "rebase", "--abort"); e != nil {
+			log.Warnf(ctx, "Could not abort rebase: %v", e)
+		}
+		if len(files) == 0 {
+			return err
+		}
+		return &SaveError{Reason: SaveConflict, Files: files, Err: err}
+	}
+	return nil
 }
 
-func (g *GitController) Pull() error {
-	cmd := exec.Command("git", "pull")
-	cmd.Dir = g.RootPath
-	return cmd.Run()
+// Fetch the latest changes from the remote
+func (g *GitController) Fetch(ctx context.Context) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.fetch(ctx)
 }
 
-// Checkout the supplied commit and notify all listeners that
+func (g *GitController) fetch(ctx context.Context) error {
+	args := []string{"fetch", g.Remote}
+	if g.Branch != "" {
+		args = append(args, g.Branch)
+	}
+	_, err := g.run(ctx, args...)
+	return err
+}
+
+// Checkout the supplied commit and notify all listeners that a checkout has happened
 func (g *GitController) Checkout(ctx context.Context, commit string) error {
-	cmd := exec.Command("git", "checkout", commit)
-	cmd.Dir = g.RootPath
-	if err := cmd.Run(); err != nil {
-		return err
+	g.mux.Lock()
+	deployment, err := g.deploy(ctx, commit, "")
+	g.mux.Unlock()
+
+	return g.finishDeploy(ctx, deployment, err)
+}
+
+// Create a deployment of the supplied commit. The head of the configured branch is deployed if no commit is supplied
+func (g *GitController) newDeployment(ctx context.Context, commit string, rolledBackFrom string) *Deployment {
+	if commit == "" {
+		commit = g.Remote + "/" + g.Branch
 	}
+	return &Deployment{
+		Ref:            commit,
+		User:           log.GetUserName(ctx),
+		CreatedAt:      time.Now().UTC(),
+		Outcome:        DeploymentSucceeded,
+		RolledBackFrom: rolledBackFrom,
+	}
+}
 
-	// NotifyAll next event that a checkout has happened
-	if err := g.bus.NotifyAll(ctx, &event.Checkout{Commit: commit}); err != nil {
-		return err
+// Checkout the supplied commit. The lock must be held. The returned deployment is finished by calling finishDeploy
+// once the lock is released
+func (g *GitController) deploy(ctx context.Context, commit string, rolledBackFrom string) (*Deployment, error) {
+	deployment := g.newDeployment(ctx, commit, rolledBackFrom)
+	if !isValidRef(deployment.Ref) {
+		return deployment, &GitError{Args: []string{"checkout", deployment.Ref}, Err: ErrInvalidRef}
 	}
 
-	return nil
+	_, err := g.run(ctx, "checkout", "--detach", deployment.Ref)
+	if out, e := g.run(ctx, "rev-parse", "--verify", "--quiet", deployment.Ref+"^{commit}"); e == nil {
+		deployment.Commit = strings.TrimSpace(string(out))
+	}
+	return deployment, err
 }
 
-func (g *GitController) Commit(message string) error {
-	cmd := exec.Command("git", "commit", "-m", message)
-	cmd.Dir = g.RootPath
-	return cmd.Run()
+// Notify all listeners that a checkout has happened, unless the checkout failed, and record the outcome in the
+// deployment log. The lock must not be held, since listeners reload the content and might call the controller
+func (g *GitController) finishDeploy(ctx context.Context, deployment *Deployment, err error) error {
+	if err == nil {
+		err = g.bus.NotifyAll(ctx, &event.Checkout{Commit: deployment.Ref})
+	}
+
+	if g.DeploymentLog != nil {
+		if err != nil {
+			deployment.Outcome = DeploymentFailed
+			deployment.Error = err.Error()
+		}
+		if e := g.DeploymentLog.Add(deployment); e != nil {
+			log.Warnf(ctx, "Could not record deployment of %s: %v", deployment.Ref, e)
+		}
+	}
+	return err
 }
 
-func (g *GitController) Push(ctx context.Context) error {
-	cmd := exec.Command("git", "push")
-	cmd.Dir = g.RootPath
-	if err := cmd.Run(); err != nil {
+func (g *GitController) History(ctx context.Context) ([]*Deployment, error) {
+	if g.DeploymentLog == nil {
+		return []*Deployment{}, nil
+	}
+	return g.DeploymentLog.List()
+}
+
+func (g *GitController) Rollback(ctx context.Context) (*Deployment, error) {
+	if g.DeploymentLog == nil {
+		return nil, nil
+	}
+
+	g.mux.Lock()
+	current, previous, err := g.DeploymentLog.Previous()
+	if err != nil || previous == nil {
+		g.mux.Unlock()
+		return nil, err
+	}
+
+	log.Infof(ctx, "Rolling back from %s to %s", current.Commit, previous.Commit)
+	deployment, err := g.deploy(ctx, previous.Commit, current.Commit)
+	g.mux.Unlock()
+
+	if err := g.finishDeploy(ctx, deployment, err); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// Commit all staged changes. The commit is attributed to the supplied author
+func (g *GitController) Commit(ctx context.Context, message string, author Author) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.commit(ctx, message, author)
+}
+
+// Commit the staged changes. Only the supplied paths are committed if any paths are supplied
+func (g *GitController) commit(ctx context.Context, message string, author Author, paths ...string) error {
+	args := append(identity(author), "commit", "-m", message)
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	_, err := g.run(ctx, args...)
+	return err
+}
+
+// Commit the supplied paths, relative to the root path, without committing any other changes. The paths are
+// committed as they are on disk, which means that removed files are committed as removed
+func (g *GitController) CommitPaths(ctx context.Context, message string, author Author, paths ...string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if _, err := g.run(ctx, append([]string{"add", "--all", "--"}, paths...)...); err != nil {
 		return err
 	}
+	args := append(identity(author), "commit", "-m", message, "--")
+	_, err := g.run(ctx, append(args, paths...)...)
+	return err
+}
 
-	// NotifyAll next event that a checkout has happened
-	if err := g.bus.NotifyAll(ctx, &event.Push{}); err != nil {
+// Create the git arguments needed for commits to be made by the supplied author
+func identity(author Author) []string {
+	email := author.Email
+	if email == "" {
+		email = author.Name + "@localhost"
+	}
+	return []string{"-c", "user.name=" + author.Name, "-c", "user.email=" + email}
+}
+
+// Push the current commit to the configured branch and notify all listeners that a push has happened
+func (g *GitController) Push(ctx context.Context) error {
+	g.mux.Lock()
+	err := g.push(ctx)
+	g.mux.Unlock()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	// NotifyAll next event that a push has happened
+	return g.bus.NotifyAll(ctx, &event.Push{})
+}
+
+func (g *GitController) push(ctx context.Context) error {
+	_, err := g.run(ctx, "push", g.Remote, "HEAD:refs/heads/"+g.Branch)
+	return err
 }
 
+// Resolve the supplied ref, such as a branch, tag or commit, into the full commit hash. Refs not found locally,
+// for example "pull/12/head", are fetched from the remote
+func (g *GitController) ResolveCommit(ctx context.Context, ref string) (string, error) {
+	if !isValidRef(ref) {
+		return "", &GitError{Args: []string{"rev-parse", ref}, Err: ErrInvalidRef}
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	candidates := []string{ref, g.Remote + "/" + ref}
+	for _, candidate := range candidates {
+		if out, err := g.run(ctx, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+
+	if _, err := g.run(ctx, "fetch", g.Remote, ref); err != nil {
+		return "", err
+	}
+	out, err := g.run(ctx, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Create a new worktree, with the supplied commit checked out, at the supplied directory
+func (g *GitController) AddWorktree(ctx context.Context, dir string, commit string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	_, err := g.run(ctx, "worktree", "add", "--detach", dir, commit)
+	return err
+}
+
+// Remove the worktree at the supplied directory
+func (g *GitController) RemoveWorktree(ctx context.Context, dir string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	_, err := g.run(ctx, "worktree", "remove", "--force", dir)
+	return err
+}
+
+// Remove information about worktrees that no longer exist
+func (g *GitController) PruneWorktrees(ctx context.Context) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	_, err := g.run(ctx, "worktree", "prune")
+	return err
+}
+
+// Fetch the time of the latest commit for each page found in the supplied directory. The directory is relative
+// to the root path and the result is keyed by the page path
+func (g *GitController) LastModified(ctx context.Context, dir string) (map[string]time.Time, error) {
+	out, err := g.run(ctx, "log", "--relative", "--name-only", "--format=%x00%cI", "--", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The log is ordered with the newest commit first, so the first time a file is found is the latest change
+	result := make(map[string]time.Time)
+	prefix := strings.Trim(path.Clean(dir), "/") + "/"
+	var commitTime time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x00") {
+			commitTime, err = time.Parse(time.RFC3339, line[1:])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(line, prefix) || path.Ext(line) != ".json" {
+			continue
+		}
+
+		p := ToPagePath(line[len(prefix):])
+		if _, ok := result[p]; !ok {
+			result[p] = commitTime
+		}
+	}
+	return result, scanner.Err()
+}
+
+// Create a new controller for the git repository found at the supplied path. Content is fetched from the
+// "main" branch of the "origin" remote by default
 func NewGitController(bus *event.Bus, rootPath string) *GitController {
-	return &GitController{bus: bus, RootPath: rootPath}
+	return &GitController{
+		bus:      bus,
+		mux:      sync.Mutex{},
+		RootPath: rootPath,
+		Remote:   "origin",
+		Branch:   "main",
+		Timeout:  time.Minute,
+		Paths:    []string{"pages", "templates", "forms"},
+	}
 }
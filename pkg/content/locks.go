@@ -0,0 +1,169 @@
+package content
+
+import (
+	"context"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The default time a lock is held unless it's renewed
+const DefaultLockTTL = 2 * time.Minute
+
+// A lock held by a user while editing a page
+type Lock struct {
+	// The path of the locked page
+	Path string
+	// The name of the user holding the lock
+	User string
+	// When the lock was acquired
+	AcquiredAt time.Time
+	// When the lock expires, unless it's renewed
+	ExpiresAt time.Time
+}
+
+// Service that keeps track of which user is editing which page. Locks expire unless they are renewed, which means
+// that editors are expected to send heartbeats by acquiring the lock again while editing
+type LockService struct {
+	// The time a lock is held unless it's renewed
+	TTL time.Duration
+
+	bus   *event.Bus
+	mux   sync.Mutex
+	locks map[string]*Lock
+}
+
+// Remove expired locks. The mutex must be held when this is called
+func (l *LockService) expire(now time.Time) []*Lock {
+	var expired []*Lock
+	for path, lock := range l.locks {
+		if !now.Before(lock.ExpiresAt) {
+			delete(l.locks, path)
+			expired = append(expired, lock)
+		}
+	}
+	return expired
+}
+
+func (l *LockService) notifyExpired(ctx context.Context, expired []*Lock) {
+	for _, lock := range expired {
+		log.Infof(ctx, "Lock of %s held by %s expired", lock.Path, lock.User)
+		if err := l.bus.NotifyAll(ctx, &event.Unlocked{Path: lock.Path, User: lock.User, Expired: true}); err != nil {
+			log.Warnf(ctx, "Could not notify listeners that %s is unlocked: %v", lock.Path, err)
+		}
+	}
+}
+
+// Acquire, or renew, the lock of the supplied page. A LockedError is returned if the page is locked by another user
+func (l *LockService) Acquire(ctx context.Context, path string, user string) (*Lock, error) {
+	path = ToPagePath(path)
+	now := time.Now().UTC()
+
+	l.mux.Lock()
+	expired := l.expire(now)
+	lock, renewed := l.locks[path]
+	if renewed && lock.User != user {
+		result := *lock
+		l.mux.Unlock()
+		l.notifyExpired(ctx, expired)
+		return nil, &LockedError{Lock: result}
+	}
+	if !renewed {
+		lock = &Lock{Path: path, User: user, AcquiredAt: now}
+		l.locks[path] = lock
+	}
+	lock.ExpiresAt = now.Add(l.TTL)
+	result := *lock
+	l.mux.Unlock()
+
+	l.notifyExpired(ctx, expired)
+	if !renewed {
+		log.Infof(ctx, "%s locked %s", user, path)
+		err := l.bus.NotifyAll(ctx, &event.Locked{Path: path, User: user, ExpiresAt: result.ExpiresAt})
+		if err != nil {
+			log.Warnf(ctx, "Could not notify listeners that %s is locked: %v", path, err)
+		}
+	}
+	return &result, nil
+}
+
+// Release the lock of the supplied page. A LockedError is returned if the page is locked by another user, unless
+// the release is forced
+func (l *LockService) Release(ctx context.Context, path string, user string, force bool) error {
+	path = ToPagePath(path)
+
+	l.mux.Lock()
+	expired := l.expire(time.Now())
+	lock, ok := l.locks[path]
+	if ok && lock.User != user && !force {
+		result := *lock
+		l.mux.Unlock()
+		l.notifyExpired(ctx, expired)
+		return &LockedError{Lock: result}
+	}
+	delete(l.locks, path)
+	l.mux.Unlock()
+
+	l.notifyExpired(ctx, expired)
+	if ok {
+		log.Infof(ctx, "%s unlocked %s", user, path)
+		err := l.bus.NotifyAll(ctx, &event.Unlocked{Path: path, User: lock.User, Forced: lock.User != user})
+		if err != nil {
+			log.Warnf(ctx, "Could not notify listeners that %s is unlocked: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// Search for the lock of the supplied page. Will return nil if the page isn't locked
+func (l *LockService) Find(path string) *Lock {
+	path = ToPagePath(path)
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if lock, ok := l.locks[path]; ok && time.Now().Before(lock.ExpiresAt) {
+		result := *lock
+		return &result
+	}
+	return nil
+}
+
+// Fetch all locks, sorted by path
+func (l *LockService) GetAll() []*Lock {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	result := []*Lock{}
+	for _, lock := range l.locks {
+		if now.Before(lock.ExpiresAt) {
+			copied := *lock
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// Check to see if the supplied user is allowed to change the supplied page. A LockedError is returned if the page
+// is locked by another user, or if the page is locked and no user is supplied
+func (l *LockService) Check(path string, user string) error {
+	if lock := l.Find(path); lock != nil && (user == "" || lock.User != user) {
+		return &LockedError{Lock: *lock}
+	}
+	return nil
+}
+
+// Create a new lock service where locks expire after the supplied time, unless they are renewed
+func NewLockService(bus *event.Bus, ttl time.Duration) *LockService {
+	return &LockService{
+		TTL:   ttl,
+		bus:   bus,
+		mux:   sync.Mutex{},
+		locks: make(map[string]*Lock),
+	}
+}
@@ -0,0 +1,84 @@
+package content
+
+import (
+	"path"
+	"strings"
+)
+
+// Metadata describing a page for search engines and social media
+type Meta struct {
+	// The title of the page
+	Title string
+
+	// A short description of the page
+	Description string
+
+	// The canonical URL of the page. Can be either relative to the site, for example "/news/first", or absolute
+	CanonicalURL string
+
+	// Directives for search engine robots, for example "noindex,nofollow"
+	Robots string
+
+	// Image used by OpenGraph and Twitter cards
+	Image string
+}
+
+// Create a copy of this metadata where all values not set are taken from the supplied defaults. The canonical
+// URL is never inherited, because it's unique for each page
+func (m Meta) Inherit(defaults Meta) Meta {
+	if m.Title == "" {
+		m.Title = defaults.Title
+	}
+	if m.Description == "" {
+		m.Description = defaults.Description
+	}
+	if m.Robots == "" {
+		m.Robots = defaults.Robots
+	}
+	if m.Image == "" {
+		m.Image = defaults.Image
+	}
+	return m
+}
+
+// Check to see if search engines are asked not to index the page
+func (m Meta) NoIndex() bool {
+	for _, directive := range strings.Split(m.Robots, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "noindex" || directive == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve the metadata for the page at the supplied path. Values not set on the page itself are inherited from
+// the MetaDefaults of the closest parent section, then from the root page "/index" and lastly from the site-wide
+// defaults.
+func ResolveMeta(repository Repository, p string, site Meta) Meta {
+	var result Meta
+	if model, err := repository.FindByPath(p); err == nil {
+		result = model.Meta
+	}
+
+	for _, section := range sections(p) {
+		if model, err := repository.FindByPath(section); err == nil {
+			result = result.Inherit(model.MetaDefaults)
+		}
+	}
+
+	return result.Inherit(site)
+}
+
+// Figure out all sections the supplied path belongs to, closest section first. For example: the path
+// "/news/2020/first" belongs to the sections "/news/2020", "/news" and "/index"
+func sections(p string) []string {
+	var result []string
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		result = append(result, dir)
+	}
+	if p != "/index" {
+		result = append(result, "/index")
+	}
+	return result
+}
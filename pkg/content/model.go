@@ -2,6 +2,9 @@ package content
 
 import "time"
 
+// Version of models that must not exist when they are saved
+const NoVersion = "none"
+
 type Model struct {
 	// Unique ID that represents this model
 	ID string
@@ -15,6 +18,18 @@ type Model struct {
 	// Type type
 	Type string
 
+	// Tags used for categorizing this model
+	Tags []string
+
+	// Metadata used by search engines and social media
+	Meta Meta
+
+	// Metadata defaults applied to all pages below this page
+	MetaDefaults Meta
+
 	// The actual content
 	Content interface{}
+
+	// Version of the model. This is the git blob ID of the file the model is stored in
+	Version string
 }
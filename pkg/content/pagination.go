@@ -0,0 +1,170 @@
+package content
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+const PagePathKey = "page_path"
+const PageNumberKey = "page_number"
+const PaginatedKey = "paginated"
+
+// Error raised when a page number that's outside of a paginated list is requested
+type PageOutOfRangeError struct {
+	Page      int
+	PageCount int
+}
+
+func (p *PageOutOfRangeError) Error() string {
+	return "page " + strconv.Itoa(p.Page) + " is out of range. There are " + strconv.Itoa(p.PageCount) + " pages"
+}
+
+// A page of items in a list
+type Pagination struct {
+	// The items on the current page
+	Items []*SearchResult
+	// The current page number, starting at 1
+	Page int
+	// The maximum number of items on each page
+	PageSize int
+	// The total number of pages
+	PageCount int
+	// The total number of items in the list
+	TotalItems int
+	// The URI of the previous page. Empty if this is the first page
+	PrevURL string
+	// The URI of the next page. Empty if this is the last page
+	NextURL string
+}
+
+// Check to see if there's a page before this page
+func (p *Pagination) HasPrev() bool {
+	return p.PrevURL != ""
+}
+
+// Check to see if there's a page after this page
+func (p *Pagination) HasNext() bool {
+	return p.NextURL != ""
+}
+
+// Create a page of the supplied items. The path is the path of the page that lists the items, for example
+// "/news". A PageOutOfRangeError is returned if the page number is outside of the list.
+func Paginate(items []*SearchResult, pageSize int, page int, path string) (*Pagination, error) {
+	if pageSize <= 0 {
+		pageSize = len(items)
+	}
+
+	pageCount := 1
+	if pageSize > 0 && len(items) > pageSize {
+		pageCount = (len(items) + pageSize - 1) / pageSize
+	}
+	if page < 1 || page > pageCount {
+		return nil, &PageOutOfRangeError{Page: page, PageCount: pageCount}
+	}
+
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+
+	result := &Pagination{
+		Items:      items[start:end],
+		Page:       page,
+		PageSize:   pageSize,
+		PageCount:  pageCount,
+		TotalItems: len(items),
+	}
+	if page > 1 {
+		result.PrevURL = PageURI(path, page-1)
+	}
+	if page < pageCount {
+		result.NextURL = PageURI(path, page+1)
+	}
+	return result, nil
+}
+
+// Create the URI of a specific page for the supplied path. The first page is the path itself, while the other
+// pages are found at "{path}/page/{page}". For example: page 2 of "/news" is found at "/news/page/2"
+func PageURI(path string, page int) string {
+	if page <= 1 {
+		if path == "/index" {
+			return "/"
+		}
+		return path
+	}
+	if path == "/index" {
+		path = ""
+	}
+	return strings.TrimSuffix(path, "/") + "/page/" + strconv.Itoa(page)
+}
+
+// Split an uri, such as "/news/page/2", into the path of the page and the page number. Will return false if the
+// uri doesn't point to a specific page.
+func SplitPageURI(uri string) (string, int, bool) {
+	i := strings.LastIndex(uri, "/page/")
+	if i < 0 {
+		return "", 0, false
+	}
+
+	page, err := strconv.Atoi(uri[i+len("/page/"):])
+	if err != nil || page < 1 {
+		return "", 0, false
+	}
+
+	path := uri[:i]
+	if path == "" {
+		path = "/index"
+	}
+	return path, page, true
+}
+
+func SetPagePath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, PagePathKey, path)
+}
+
+func SetPageNumber(ctx context.Context, page int) context.Context {
+	return context.WithValue(ctx, PageNumberKey, page)
+}
+
+// Keeps track of whether the requested page number is used when a page is rendered
+type paginated struct {
+	used bool
+}
+
+// Start keeping track of whether the requested page number is used when the page is rendered
+func TrackPagination(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PaginatedKey, &paginated{})
+}
+
+// Mark the requested page number as used. Called when a list is paginated while rendering a page
+func MarkPaginated(ctx context.Context) {
+	if p, ok := ctx.Value(PaginatedKey).(*paginated); ok {
+		p.used = true
+	}
+}
+
+// Check to see if the requested page number was used when the page was rendered
+func IsPaginated(ctx context.Context) bool {
+	if p, ok := ctx.Value(PaginatedKey).(*paginated); ok {
+		return p.used
+	}
+	return false
+}
+
+// Fetch the path of the page being rendered. Will return an empty string if no path is set
+func GetPagePath(ctx context.Context) string {
+	if ret, ok := ctx.Value(PagePathKey).(string); ok {
+		return ret
+	}
+	return ""
+}
+
+// Fetch the page number requested when rendering a paginated page. Defaults to the first page
+func GetPageNumber(ctx context.Context) int {
+	if ret, ok := ctx.Value(PageNumberKey).(int); ok {
+		return ret
+	}
+	return 1
+}
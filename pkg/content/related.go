@@ -0,0 +1,247 @@
+package content
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The maximum number of related pages kept for each page
+const maxRelated = 50
+
+const (
+	// Score given for each tag two pages have in common
+	tagScore = 3.0
+	// Score given if two pages are of the same type
+	typeScore = 1.0
+	// Score given if the text of two pages are identical. Pages with fewer terms in common receive a lower score
+	termScore = 5.0
+)
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true, "for": true,
+	"from": true, "has": true, "in": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "were": true, "will": true, "with": true,
+}
+
+// The maximum number of terms used for each page. Only the terms with the highest TF-IDF weight are used, since
+// they are the ones that describe the page best
+const maxTerms = 20
+
+// Tags and terms shared by more pages than this are ignored when searching for related pages. They say little
+// about how related two pages are and comparing all pages that share them would be too slow
+const maxPostings = 100
+
+type relatedCandidate struct {
+	result *SearchResult
+	score  float64
+}
+
+// A weighted term of a page
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// Figure out the related pages for all supplied models. The result is keyed by the page path and each list is
+// sorted by relevance, most related page first. Pages are only compared with pages they share tags or terms with,
+// found using an index, which means that the time it takes grows linearly with the number of pages
+func buildRelated(models map[string]*Model) map[string][]*SearchResult {
+	var paths []string
+	for p := range models {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	// Calculate a TF-IDF vector for the text of each page
+	terms := make(map[string]map[string]float64)
+	documentFrequency := make(map[string]int)
+	for _, p := range paths {
+		frequency := termFrequency(models[p])
+		terms[p] = frequency
+		for term := range frequency {
+			documentFrequency[term]++
+		}
+	}
+	vectors := make(map[string]map[string]float64)
+	norms := make(map[string]float64)
+	for _, p := range paths {
+		var weighted []weightedTerm
+		for term, count := range terms[p] {
+			weight := count * math.Log(1+float64(len(paths))/float64(documentFrequency[term]))
+			weighted = append(weighted, weightedTerm{term, weight})
+		}
+		sort.Slice(weighted, func(i, j int) bool {
+			if weighted[i].weight != weighted[j].weight {
+				return weighted[i].weight > weighted[j].weight
+			}
+			return weighted[i].term < weighted[j].term
+		})
+		if len(weighted) > maxTerms {
+			weighted = weighted[:maxTerms]
+		}
+
+		vector := make(map[string]float64)
+		var norm float64
+		for _, w := range weighted {
+			vector[w.term] = w.weight
+			norm += w.weight * w.weight
+		}
+		vectors[p] = vector
+		norms[p] = math.Sqrt(norm)
+	}
+
+	// Index the pages by their tags, terms and types
+	tags := make(map[string]map[string]bool)
+	tagIndex := make(map[string][]string)
+	termIndex := make(map[string][]string)
+	typeIndex := make(map[string][]string)
+	for _, p := range paths {
+		model := models[p]
+		tags[p] = make(map[string]bool)
+		for _, tag := range model.Tags {
+			tag = strings.ToLower(tag)
+			if !tags[p][tag] {
+				tags[p][tag] = true
+				tagIndex[tag] = append(tagIndex[tag], p)
+			}
+		}
+		for term := range vectors[p] {
+			termIndex[term] = append(termIndex[term], p)
+		}
+		if model.Type != "" {
+			typeIndex[model.Type] = append(typeIndex[model.Type], p)
+		}
+	}
+
+	result := make(map[string][]*SearchResult)
+	for _, p := range paths {
+		model := models[p]
+
+		// Search for pages sharing tags or terms with this page
+		found := make(map[string]bool)
+		addCandidates := func(postings []string) {
+			if len(postings) > maxPostings {
+				return
+			}
+			for _, other := range postings {
+				found[other] = true
+			}
+		}
+		for tag := range tags[p] {
+			addCandidates(tagIndex[tag])
+		}
+		for term := range vectors[p] {
+			addCandidates(termIndex[term])
+		}
+		delete(found, p)
+
+		var candidates []*relatedCandidate
+		for other := range found {
+			otherModel := models[other]
+			score := float64(sharedTags(tags[p], tags[other])) * tagScore
+			if model.Type != "" && model.Type == otherModel.Type {
+				score += typeScore
+			}
+			if norms[p] > 0 && norms[other] > 0 {
+				var dot float64
+				for term, weight := range vectors[p] {
+					dot += weight * vectors[other][term]
+				}
+				score += termScore * dot / (norms[p] * norms[other])
+			}
+
+			if score > 0 {
+				candidates = append(candidates, &relatedCandidate{&SearchResult{other, otherModel}, score})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].score != candidates[j].score {
+				return candidates[i].score > candidates[j].score
+			}
+			return candidates[i].result.Path < candidates[j].result.Path
+		})
+		if len(candidates) > maxRelated {
+			candidates = candidates[:maxRelated]
+		}
+
+		// Pages that only share the type with this page are the least related ones
+		for _, other := range typeIndex[model.Type] {
+			if len(candidates) >= maxRelated {
+				break
+			}
+			if other != p && !found[other] {
+				candidates = append(candidates, &relatedCandidate{&SearchResult{other, models[other]}, typeScore})
+			}
+		}
+
+		related := make([]*SearchResult, len(candidates))
+		for i, c := range candidates {
+			related[i] = c.result
+		}
+		result[p] = related
+	}
+	return result
+}
+
+// Count the number of tags found in both sets
+func sharedTags(a map[string]bool, b map[string]bool) int {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	var result int
+	for tag := range a {
+		if b[tag] {
+			result++
+		}
+	}
+	return result
+}
+
+// Count the number of times each term is found in the text fields of the supplied model
+func termFrequency(model *Model) map[string]float64 {
+	var texts []string
+	texts = append(texts, model.Meta.Title, model.Meta.Description)
+	if b, err := json.Marshal(model.Content); err == nil {
+		var content interface{}
+		if err := json.Unmarshal(b, &content); err == nil {
+			texts = collectTexts(content, texts)
+		}
+	}
+
+	result := make(map[string]float64)
+	for _, text := range texts {
+		text = htmlTags.ReplaceAllString(text, " ")
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if len(word) > 2 && !stopWords[word] {
+				result[word]++
+			}
+		}
+	}
+	return result
+}
+
+func collectTexts(value interface{}, texts []string) []string {
+	switch v := value.(type) {
+	case string:
+		texts = append(texts, v)
+	case []interface{}:
+		for _, item := range v {
+			texts = collectTexts(item, texts)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			texts = collectTexts(item, texts)
+		}
+	}
+	return texts
+}
@@ -11,17 +11,21 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
 )
 
 type pageData struct {
-	ID        string
-	CreatedAt time.Time
-	View      string
-	Type      string
-	Content   json.RawMessage
+	ID           string
+	CreatedAt    time.Time
+	View         string
+	Type         string
+	Tags         []string
+	Meta         Meta
+	MetaDefaults Meta
+	Content      json.RawMessage
 }
 
 // Function for unmarshal the actual content
@@ -33,8 +37,18 @@ type Repository interface {
 	//  repository.RegisterModelType("models.News", models.JsonToNews)
 	RegisterModelType(name string, fn UnmarshalContentFunc)
 
-	// Save the supplied model. If the save failed for some reason then an error will be returned
-	Save(ctx context.Context, path string, model *Model) (*Model, error)
+	// Fetch the functions used when unmarshalling the content of all registered model types
+	GetUnmarshalFuncs() map[string]UnmarshalContentFunc
+
+	// Fetch the Go type of the content for all registered model types. The type is figured out when the model
+	// type is registered by unmarshalling an empty JSON object
+	GetModelTypes() map[string]reflect.Type
+
+	// Save the supplied model. If the save failed for some reason then an error will be returned. A ConflictError
+	// is returned if the model has a version and the saved file no longer is of that version, or if the version is
+	// NoVersion and the file exists. A LockedError is returned if the page is locked by someone other than the
+	// supplied user, which is the user saving the page
+	Save(ctx context.Context, path string, model *Model, user string) (*Model, error)
 
 	// Search for the model associated with the supplied path. The repository will return an error and the default
 	// 404 model if the supplied path is not found.
@@ -51,20 +65,59 @@ type Repository interface {
 
 	// Fetch all
 	GetAll() []*SearchResult
+
+	// Fetch the pages most related to the page at the supplied path, most related page first. Pages are related
+	// if they share tags, are of the same type or have terms in common in their text fields. The relations are
+	// calculated when the repository is reloaded.
+	Related(path string, n int) []*SearchResult
+
+	// Fetch the service keeping track of which user is editing which page
+	Locks() *LockService
 }
 
 type RepositoryImpl struct {
-	rootPath string
-	mux      sync.Mutex
-	Data     map[string]*Model
-	Types    map[string]UnmarshalContentFunc
+	bus        *event.Bus
+	rootPath   string
+	mux        sync.Mutex
+	saveMux    sync.Mutex
+	locks      *LockService
+	Data       map[string]*Model
+	related    map[string][]*SearchResult
+	Types      map[string]UnmarshalContentFunc
+	ModelTypes map[string]reflect.Type
 }
 
 func (r *RepositoryImpl) RegisterModelType(view string, fn UnmarshalContentFunc) {
 	r.Types[view] = fn
+	if value, err := fn(json.RawMessage("{}")); err == nil && value != nil {
+		r.ModelTypes[view] = reflect.TypeOf(value)
+	}
+}
+
+func (r *RepositoryImpl) GetUnmarshalFuncs() map[string]UnmarshalContentFunc {
+	result := make(map[string]UnmarshalContentFunc)
+	for name, fn := range r.Types {
+		result[name] = fn
+	}
+	return result
+}
+
+func (r *RepositoryImpl) GetModelTypes() map[string]reflect.Type {
+	result := make(map[string]reflect.Type)
+	for name, t := range r.ModelTypes {
+		result[name] = t
+	}
+	return result
 }
 
-func (r *RepositoryImpl) Save(ctx context.Context, p string, model *Model) (*Model, error) {
+func (r *RepositoryImpl) Save(ctx context.Context, p string, model *Model, user string) (*Model, error) {
+	if strings.Contains(p, "..") {
+		return nil, errors.New("invalid path: " + p)
+	}
+	if err := r.locks.Check(p, user); err != nil {
+		return nil, err
+	}
+
 	var id = model.ID
 	if id == "" {
 		id = uuid.New().String()
@@ -81,6 +134,9 @@ func (r *RepositoryImpl) Save(ctx context.Context, p string, model *Model) (*Mod
 		model.CreatedAt,
 		model.View,
 		model.Type,
+		model.Tags,
+		model.Meta,
+		model.MetaDefaults,
 		contentJson,
 	}
 	b, err := json.Marshal(output)
@@ -88,14 +144,45 @@ func (r *RepositoryImpl) Save(ctx context.Context, p string, model *Model) (*Mod
 		return nil, err
 	}
 
-	absolutePath := path.Join(r.rootPath, p)
-	err = ioutil.WriteFile(absolutePath+".json", b, 0644)
+	absolutePath := path.Join(r.rootPath, p) + ".json"
+
+	// Make sure that the file isn't changed between the version check and the write
+	r.saveMux.Lock()
+	defer r.saveMux.Unlock()
+	if model.Version != "" {
+		var actual string
+		if existing, err := ioutil.ReadFile(absolutePath); err == nil {
+			actual = BlobID(existing)
+		}
+		expected := model.Version
+		if expected == NoVersion {
+			expected = ""
+		}
+		if actual != expected {
+			return nil, &ConflictError{Path: p, Expected: expected, Actual: actual}
+		}
+	}
+
+	if err := os.MkdirAll(path.Dir(absolutePath), 0755); err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(absolutePath, b, 0644)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Infof(ctx, "Sucessfully saved %s", p)
 	model.ID = id
+	model.Version = BlobID(b)
+
+	r.mux.Lock()
+	if r.Data != nil {
+		r.Data[ToPagePath(p)] = model
+	}
+	r.mux.Unlock()
+	if err := r.bus.NotifyAll(ctx, &event.PageSaved{Path: p, ID: id}); err != nil {
+		log.Warnf(ctx, "Could not notify listeners that %s is saved: %v", p, err)
+	}
 	return model, nil
 }
 
@@ -121,26 +208,24 @@ func (r *RepositoryImpl) Reload(ctx context.Context) error {
 			if filepath.Ext(path) == ".json" {
 				file, err := os.Open(path)
 				if err != nil {
-					log.Errorf(ctx, "Could not open: %s. %e", path, err)
+					log.Errorf(ctx, "Could not open: %s. %v", path, err)
 					return nil
 				}
 				defer file.Close()
 
 				b, err := ioutil.ReadAll(file)
 				if err != nil {
-					log.Errorf(ctx, "Could not read content from: %s. %e", path, err)
+					log.Errorf(ctx, "Could not read content from: %s. %v", path, err)
 					return nil
 				}
 
 				model, err := r.unmarshal(path, string(b))
 				if err != nil {
-					log.Errorf(ctx, "Could not unmarshal content from: %s. %e", path, err)
+					log.Errorf(ctx, "Could not unmarshal content from: %s. %v", path, err)
 					return nil
 				}
 
-				path = path[len(r.rootPath) : len(path)-5]
-				path = strings.ToLower(path)
-				path = strings.Replace(path, "\\", "/", -1)
+				path = ToPagePath(path[len(r.rootPath):])
 				models[path] = model
 				log.Infof(ctx, "Loaded %s", path)
 			}
@@ -148,9 +233,12 @@ func (r *RepositoryImpl) Reload(ctx context.Context) error {
 		return nil
 	})
 
+	related := buildRelated(models)
+
 	r.mux.Lock()
 	defer r.mux.Unlock()
 	r.Data = models
+	r.related = related
 	return nil
 }
 
@@ -170,11 +258,15 @@ func (r *RepositoryImpl) unmarshal(path string, str string) (*Model, error) {
 	}
 
 	return &Model{
-		ID:        raw.ID,
-		CreatedAt: raw.CreatedAt,
-		View:      raw.View,
-		Type:      raw.Type,
-		Content:   content,
+		ID:           raw.ID,
+		CreatedAt:    raw.CreatedAt,
+		View:         raw.View,
+		Type:         raw.Type,
+		Tags:         raw.Tags,
+		Meta:         raw.Meta,
+		MetaDefaults: raw.MetaDefaults,
+		Content:      content,
+		Version:      BlobID([]byte(str)),
 	}, nil
 }
 
@@ -220,10 +312,39 @@ func (r *RepositoryImpl) GetAll() []*SearchResult {
 	return result
 }
 
+func (r *RepositoryImpl) Related(path string, n int) []*SearchResult {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	related := r.related[path]
+	if n >= 0 && n < len(related) {
+		related = related[:n]
+	}
+	return append([]*SearchResult{}, related...)
+}
+
+func (r *RepositoryImpl) Locks() *LockService {
+	return r.locks
+}
+
+// Convert the path of a content file, relative to the content root, into the path of a page. For example:
+// "news/First.json" becomes "/news/first"
+func ToPagePath(file string) string {
+	file = strings.Replace(file, "\\", "/", -1)
+	file = strings.TrimSuffix(file, filepath.Ext(file))
+	file = strings.ToLower(file)
+	if !strings.HasPrefix(file, "/") {
+		file = "/" + file
+	}
+	return file
+}
+
 func NewRepository(bus *event.Bus, rootPath string) Repository {
 	result := &RepositoryImpl{
-		rootPath: rootPath,
-		Types:    make(map[string]UnmarshalContentFunc),
+		bus:        bus,
+		rootPath:   rootPath,
+		locks:      NewLockService(bus, DefaultLockTTL),
+		Types:      make(map[string]UnmarshalContentFunc),
+		ModelTypes: make(map[string]reflect.Type),
 	}
 	bus.AddListener(result)
 	return result
@@ -0,0 +1,16 @@
+package content
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+)
+
+// Calculate the git blob ID of the supplied file content. This is the same ID as git uses for the content, which
+// means that it can be compared with the output from "git hash-object"
+func BlobID(b []byte) string {
+	hash := sha1.New()
+	_, _ = hash.Write([]byte("blob " + strconv.Itoa(len(b)) + "\x00"))
+	_, _ = hash.Write(b)
+	return hex.EncodeToString(hash.Sum(nil))
+}
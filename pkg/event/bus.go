@@ -7,7 +7,7 @@ type Bus struct {
 }
 
 // Add a new event listener
-func (b Bus) AddListener(listener Listener) {
+func (b *Bus) AddListener(listener Listener) {
 	b.listeners = append(b.listeners, listener)
 }
 
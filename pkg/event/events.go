@@ -1,5 +1,7 @@
 package event
 
+import "time"
+
 // Represents an event that a checkout has happened
 type Checkout struct {
 	// The commit that's been changed out
@@ -9,3 +11,43 @@ type Checkout struct {
 // Represents when changes are pushed to the remote server
 type Push struct {
 }
+
+// Represents when a form submission is received
+type FormSubmitted struct {
+	// The ID of the form
+	Form string
+	// The ID of the submission
+	Submission string
+	// The submitted values
+	Values map[string]string
+}
+
+// Represents when a page is saved
+type PageSaved struct {
+	// The path of the saved page
+	Path string
+	// The ID of the saved page
+	ID string
+}
+
+// Represents when a user starts editing a page
+type Locked struct {
+	// The path of the locked page
+	Path string
+	// The name of the user holding the lock
+	User string
+	// When the lock expires, unless it's renewed
+	ExpiresAt time.Time
+}
+
+// Represents when a page is no longer locked
+type Unlocked struct {
+	// The path of the unlocked page
+	Path string
+	// The name of the user that held the lock
+	User string
+	// If the lock was removed by someone other than the user holding it
+	Forced bool
+	// If the lock was removed because it expired
+	Expired bool
+}
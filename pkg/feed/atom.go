@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"encoding/xml"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"time"
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string    `xml:"title"`
+	ID      string    `xml:"id"`
+	Link    atomLink  `xml:"link"`
+	Updated string    `xml:"updated"`
+	Summary *atomText `xml:"summary,omitempty"`
+	Content *atomText `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+func atomDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (f *Feeds) atom(c config.FeedConfig, entries []*entry) (*Document, error) {
+	feed := &atomFeed{
+		Title:    c.Title,
+		Subtitle: c.Description,
+		ID:       f.site.AbsoluteURL(c.Path),
+		Updated:  atomDate(updated(entries)),
+		Links: []atomLink{
+			{Href: f.site.AbsoluteURL(c.Path), Rel: "self"},
+			{Href: f.site.AbsoluteURL(link(c)), Rel: "alternate"},
+		},
+	}
+	for _, e := range entries {
+		id := e.URL
+		if e.ID != "" {
+			id = "urn:uuid:" + e.ID
+		}
+
+		item := atomEntry{
+			Title:   e.Title,
+			ID:      id,
+			Link:    atomLink{Href: e.URL},
+			Updated: atomDate(e.Date),
+		}
+		if e.Summary != "" {
+			item.Summary = &atomText{Type: "text", Value: e.Summary}
+		}
+		if e.Content != "" {
+			item.Content = &atomText{Type: "html", Value: e.Content}
+		}
+		feed.Entries = append(feed.Entries, item)
+	}
+
+	b, err := xml.Marshal(feed)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		ContentType: "application/atom+xml; charset=utf-8",
+		Body:        append([]byte(xml.Header), b...),
+	}, nil
+}
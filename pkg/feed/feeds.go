@@ -0,0 +1,209 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RSS  = "rss"
+	Atom = "atom"
+)
+
+const defaultLimit = 20
+
+// A generated feed
+type Document struct {
+	// The content type of the feed, for example "application/rss+xml"
+	ContentType string
+	// The actual feed
+	Body []byte
+}
+
+// An entry in a feed
+type entry struct {
+	ID      string
+	URL     string
+	Title   string
+	Summary string
+	Content string
+	Date    time.Time
+}
+
+// Service responsible for generating all configured feeds. Generated feeds are cached until new content is
+// checked out.
+type Feeds struct {
+	repository content.Repository
+	acl        acl.Service
+	site       config.SiteConfig
+	configs    map[string]config.FeedConfig
+	mux        sync.Mutex
+	documents  map[string]*Document
+}
+
+// Check to see if a feed is served at the supplied uri
+func (f *Feeds) IsFeed(uri string) bool {
+	_, ok := f.configs[uri]
+	return ok
+}
+
+// Fetch the feed served at the supplied uri. A content.NotFoundError is returned if no feed is configured
+// for the uri.
+func (f *Feeds) Find(uri string) (*Document, error) {
+	c, ok := f.configs[uri]
+	if !ok {
+		return nil, content.NewNotFoundError(uri)
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if document, ok := f.documents[uri]; ok {
+		return document, nil
+	}
+
+	document, err := f.generate(c)
+	if err != nil {
+		return nil, err
+	}
+	f.documents[uri] = document
+	return document, nil
+}
+
+// Forcefully reset all generated feeds
+func (f *Feeds) Reset() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.documents = make(map[string]*Document)
+}
+
+func (f *Feeds) OnEvent(_ context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		f.Reset()
+	}
+	return nil
+}
+
+func (f *Feeds) generate(c config.FeedConfig) (*Document, error) {
+	var results []*content.SearchResult
+	if c.Type != "" {
+		results = f.repository.Search(c.Type)
+	} else {
+		results = f.repository.GetAll()
+	}
+
+	var entries []*entry
+	for _, result := range results {
+		if !strings.HasPrefix(result.Path, c.Prefix) || !acl.IsPublic(f.acl, result.Path) {
+			continue
+		}
+
+		model := result.Model
+		entries = append(entries, &entry{
+			ID:      model.ID,
+			URL:     f.site.AbsoluteURL(result.Path),
+			Title:   toString(model.Field(c.Fields.Title)),
+			Summary: toString(model.Field(c.Fields.Summary)),
+			Content: toString(model.Field(c.Fields.Content)),
+			Date:    toTime(model.Field(c.Fields.Date)),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[j].Date.Before(entries[i].Date)
+	})
+	if len(entries) > c.Limit {
+		entries = entries[:c.Limit]
+	}
+
+	switch c.Format {
+	case RSS:
+		return f.rss(c, entries)
+	case Atom:
+		return f.atom(c, entries)
+	}
+	return nil, fmt.Errorf("unknown feed format: %s", c.Format)
+}
+
+// Figure out the link to the web page corresponding to the feed
+func link(c config.FeedConfig) string {
+	if c.Prefix == "" {
+		return "/"
+	}
+	return c.Prefix
+}
+
+// Figure out when the feed was last updated, based on the newest entry
+func updated(entries []*entry) time.Time {
+	if len(entries) > 0 {
+		return entries[0].Date
+	}
+	return time.Time{}
+}
+
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+func toTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case *time.Time:
+		if v != nil {
+			return *v
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Create a service for the supplied feed configurations. Pages that require more than the Read role are never
+// part of a feed.
+func NewFeeds(bus *event.Bus, repository content.Repository, service acl.Service, site config.SiteConfig,
+	configs []config.FeedConfig) *Feeds {
+	impl := &Feeds{
+		repository: repository,
+		acl:        service,
+		site:       site,
+		configs:    make(map[string]config.FeedConfig),
+		documents:  make(map[string]*Document),
+	}
+	for _, c := range configs {
+		if c.Format == "" {
+			c.Format = RSS
+		}
+		if c.Limit <= 0 {
+			c.Limit = defaultLimit
+		}
+		if c.Fields.Title == "" {
+			c.Fields.Title = "Meta.Title"
+		}
+		if c.Fields.Summary == "" {
+			c.Fields.Summary = "Meta.Description"
+		}
+		if c.Fields.Date == "" {
+			c.Fields.Date = "CreatedAt"
+		}
+		impl.configs[c.Path] = c
+	}
+	bus.AddListener(impl)
+	return impl
+}
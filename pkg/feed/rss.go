@@ -0,0 +1,78 @@
+package feed
+
+import (
+	"encoding/xml"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"time"
+)
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        *rssGUID `xml:"guid,omitempty"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Content     string   `xml:"content:encoded,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XMLNSContent string     `xml:"xmlns:content,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+func rssDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+func (f *Feeds) rss(c config.FeedConfig, entries []*entry) (*Document, error) {
+	feed := &rssFeed{
+		Version:      "2.0",
+		XMLNSContent: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:         c.Title,
+			Link:          f.site.AbsoluteURL(link(c)),
+			Description:   c.Description,
+			LastBuildDate: rssDate(updated(entries)),
+		},
+	}
+	for _, e := range entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			PubDate:     rssDate(e.Date),
+			Description: e.Summary,
+			Content:     e.Content,
+		}
+		if e.ID != "" {
+			item.GUID = &rssGUID{IsPermaLink: false, Value: e.ID}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	b, err := xml.Marshal(feed)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		ContentType: "application/rss+xml; charset=utf-8",
+		Body:        append([]byte(xml.Header), b...),
+	}, nil
+}
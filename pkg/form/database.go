@@ -0,0 +1,113 @@
+package form
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var validID = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Database containing all form definitions. Each form is defined in a json file in the database directory
+type Database struct {
+	directory string
+	mux       sync.Mutex
+	forms     map[string]*Definition
+}
+
+// Search for the form with the supplied ID
+func (d *Database) Find(id string) (*Definition, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if form, ok := d.forms[id]; ok {
+		return form, nil
+	}
+	return nil, &NotFoundError{ID: id}
+}
+
+func (d *Database) load(ctx context.Context) error {
+	log.Infof(ctx, "Loading forms from %s", d.directory)
+	forms := make(map[string]*Definition)
+	files, err := ioutil.ReadDir(d.directory)
+	if err != nil && !os.IsNotExist(err) {
+		return NewLoadError("Could not read forms directory: '%s' because: %v", d.directory, err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(d.directory, file.Name())
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return NewLoadError("Could not read form: '%s' because: %v", path, err)
+		}
+
+		var form Definition
+		if err := json.Unmarshal(bytes, &form); err != nil {
+			return NewLoadError("Could not parse form: '%s' because: %v", path, err)
+		}
+		if form.ID == "" {
+			form.ID = strings.TrimSuffix(file.Name(), ".json")
+		}
+		if !validID.MatchString(form.ID) {
+			log.Warnf(ctx, "Ignoring form '%s' found in %s because of an invalid ID", form.ID, path)
+			continue
+		}
+		for _, f := range form.Fields {
+			if f.Pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return NewLoadError("Invalid pattern for field '%s' in form: '%s' because: %v", f.Name, path, err)
+			}
+		}
+		forms[form.ID] = &form
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.forms = forms
+	return nil
+}
+
+func (d *Database) OnEvent(ctx context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		if err := d.load(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create a new database containing all forms found in the supplied directory
+func NewDatabase(bus *event.Bus, directory string) *Database {
+	impl, err := LoadDatabase(bus, directory)
+	if err != nil {
+		panic(err)
+	}
+	return impl
+}
+
+// Load a new database containing all forms found in the supplied directory. Will return a LoadError if a form
+// can't be read or parsed
+func LoadDatabase(bus *event.Bus, directory string) (*Database, error) {
+	impl := &Database{
+		directory: directory,
+		mux:       sync.Mutex{},
+		forms:     make(map[string]*Definition),
+	}
+	if err := impl.load(context.Background()); err != nil {
+		return nil, err
+	}
+	bus.AddListener(impl)
+	return impl, nil
+}
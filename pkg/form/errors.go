@@ -0,0 +1,27 @@
+package form
+
+import "fmt"
+
+// Error raised when a form could not be found
+type NotFoundError struct {
+	ID string
+}
+
+func (n *NotFoundError) Error() string {
+	return fmt.Sprintf("form '%s' is not found", n.ID)
+}
+
+// Error raised when form definitions or submissions could not be loaded
+type LoadError struct {
+	message string
+}
+
+func (l *LoadError) Error() string {
+	return l.message
+}
+
+func NewLoadError(format string, v ...interface{}) *LoadError {
+	return &LoadError{
+		message: fmt.Sprintf(format, v...),
+	}
+}
@@ -0,0 +1,212 @@
+package form
+
+import (
+	"fmt"
+	"html/template"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Types of form fields
+const (
+	Text     = "text"
+	TextArea = "textarea"
+	Email    = "email"
+	Number   = "number"
+	Tel      = "tel"
+	URL      = "url"
+	Checkbox = "checkbox"
+	Select   = "select"
+	Hidden   = "hidden"
+)
+
+// A field in a form, including the rules used when validating submitted values
+type Field struct {
+	// The name of the field
+	Name string
+	// The label shown next to the field
+	Label string
+	// The type of field, for example "text" or "email". Defaults to "text"
+	Type string
+	// Text shown in the field when it's empty
+	Placeholder string
+	// If a value is required
+	Required bool
+	// The minimum number of characters
+	MinLength int
+	// The maximum number of characters
+	MaxLength int
+	// A regular expression the value must match
+	Pattern string
+	// The minimum value of a number field
+	Min *float64
+	// The maximum value of a number field
+	Max *float64
+	// The options available in a select field
+	Options []string
+}
+
+// Definition of a form managed as content
+type Definition struct {
+	// Unique ID of the form, for example "contact". Defaults to the name of the file the form is defined in
+	ID string
+	// The title of the form
+	Title string
+	// All fields in the form
+	Fields []*Field
+	// The label of the submit button
+	SubmitLabel string
+	// The page users are redirected to after a successful submission
+	SuccessPage string
+}
+
+// Validate the supplied values. The result contains a message for each field with an invalid value and is empty
+// if all values are valid.
+func (d *Definition) Validate(values map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, f := range d.Fields {
+		if message := f.validate(values[f.Name]); message != "" {
+			result[f.Name] = message
+		}
+	}
+	return result
+}
+
+func (f *Field) label() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+func (f *Field) validate(value string) string {
+	if strings.TrimSpace(value) == "" {
+		if f.Required {
+			return f.label() + " is required"
+		}
+		return ""
+	}
+
+	length := utf8.RuneCountInString(value)
+	if f.MinLength > 0 && length < f.MinLength {
+		return fmt.Sprintf("%s must be at least %d characters", f.label(), f.MinLength)
+	}
+	if f.MaxLength > 0 && length > f.MaxLength {
+		return fmt.Sprintf("%s must be at most %d characters", f.label(), f.MaxLength)
+	}
+	if f.Pattern != "" {
+		if matched, err := regexp.MatchString("^(?:"+f.Pattern+")$", value); err != nil || !matched {
+			return f.label() + " has an invalid format"
+		}
+	}
+
+	switch f.Type {
+	case Email:
+		if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+			return f.label() + " must be a valid email address"
+		}
+	case URL:
+		if u, err := url.ParseRequestURI(value); err != nil || u.Host == "" {
+			return f.label() + " must be a valid URL"
+		}
+	case Number:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return f.label() + " must be a number"
+		}
+		if f.Min != nil && number < *f.Min {
+			return fmt.Sprintf("%s must be at least %v", f.label(), *f.Min)
+		}
+		if f.Max != nil && number > *f.Max {
+			return fmt.Sprintf("%s must be at most %v", f.label(), *f.Max)
+		}
+	case Select:
+		for _, option := range f.Options {
+			if option == value {
+				return ""
+			}
+		}
+		return f.label() + " has an invalid value"
+	}
+	return ""
+}
+
+// Render the form as HTML. The form is posted to the supplied action
+func (d *Definition) Render(action string) template.HTML {
+	e := template.HTMLEscapeString
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, `<form method="post" action="%s" id="form-%s" class="form">`+"\n", e(action), e(d.ID))
+	for _, f := range d.Fields {
+		id := e("form-" + d.ID + "-" + f.Name)
+		name := e(f.Name)
+
+		var attributes strings.Builder
+		if f.Required {
+			attributes.WriteString(" required")
+		}
+		if f.MinLength > 0 {
+			_, _ = fmt.Fprintf(&attributes, ` minlength="%d"`, f.MinLength)
+		}
+		if f.MaxLength > 0 {
+			_, _ = fmt.Fprintf(&attributes, ` maxlength="%d"`, f.MaxLength)
+		}
+		if f.Pattern != "" {
+			_, _ = fmt.Fprintf(&attributes, ` pattern="%s"`, e(f.Pattern))
+		}
+		if f.Min != nil {
+			_, _ = fmt.Fprintf(&attributes, ` min="%v"`, *f.Min)
+		}
+		if f.Max != nil {
+			_, _ = fmt.Fprintf(&attributes, ` max="%v"`, *f.Max)
+		}
+		if f.Placeholder != "" {
+			_, _ = fmt.Fprintf(&attributes, ` placeholder="%s"`, e(f.Placeholder))
+		}
+
+		if f.Type == Hidden {
+			_, _ = fmt.Fprintf(&sb, `<input type="hidden" id="%s" name="%s">`+"\n", id, name)
+			continue
+		}
+
+		sb.WriteString(`<div class="form-field">` + "\n")
+		if f.Type == Checkbox {
+			_, _ = fmt.Fprintf(&sb, `<input type="checkbox" id="%s" name="%s" value="true"%s>`+"\n", id, name,
+				attributes.String())
+			_, _ = fmt.Fprintf(&sb, `<label for="%s">%s</label>`+"\n", id, e(f.label()))
+			sb.WriteString("</div>\n")
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&sb, `<label for="%s">%s</label>`+"\n", id, e(f.label()))
+		switch f.Type {
+		case TextArea:
+			_, _ = fmt.Fprintf(&sb, `<textarea id="%s" name="%s"%s></textarea>`+"\n", id, name, attributes.String())
+		case Select:
+			_, _ = fmt.Fprintf(&sb, `<select id="%s" name="%s"%s>`+"\n", id, name, attributes.String())
+			for _, option := range f.Options {
+				_, _ = fmt.Fprintf(&sb, `<option value="%s">%s</option>`+"\n", e(option), e(option))
+			}
+			sb.WriteString("</select>\n")
+		default:
+			t := f.Type
+			if t == "" {
+				t = Text
+			}
+			_, _ = fmt.Fprintf(&sb, `<input type="%s" id="%s" name="%s"%s>`+"\n", e(t), id, name,
+				attributes.String())
+		}
+		sb.WriteString("</div>\n")
+	}
+
+	label := d.SubmitLabel
+	if label == "" {
+		label = "Send"
+	}
+	_, _ = fmt.Fprintf(&sb, `<button type="submit">%s</button>`+"\n", e(label))
+	sb.WriteString("</form>")
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,173 @@
+package form
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A submission of a form
+type Submission struct {
+	// Unique ID of the submission
+	ID string
+	// The ID of the submitted form
+	Form string
+	// When the form was submitted
+	CreatedAt time.Time
+	// The submitted values
+	Values map[string]string
+}
+
+// Store where submissions are saved. Submissions are appended, one json record per line, to a file named after
+// the form. The store is kept outside of the content, since submissions are not content.
+type Submissions struct {
+	bus       *event.Bus
+	directory string
+	mux       sync.Mutex
+}
+
+func (s *Submissions) path(id string) string {
+	return filepath.Join(s.directory, id+".jsonl")
+}
+
+// Save a submission of the supplied form. Only values for fields in the form are saved. Listeners are
+// notified with a FormSubmitted event when the submission is saved
+func (s *Submissions) Add(ctx context.Context, form *Definition, values map[string]string) (*Submission, error) {
+	submission := &Submission{
+		ID:        uuid.New().String(),
+		Form:      form.ID,
+		CreatedAt: time.Now().UTC(),
+		Values:    make(map[string]string),
+	}
+	for _, f := range form.Fields {
+		if value, ok := values[f.Name]; ok {
+			submission.Values[f.Name] = value
+		}
+	}
+
+	bytes, err := json.Marshal(submission)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.append(form.ID, append(bytes, '\n')); err != nil {
+		return nil, err
+	}
+	log.Infof(ctx, "Received submission %s of form %s", submission.ID, form.ID)
+
+	err = s.bus.NotifyAll(ctx, &event.FormSubmitted{
+		Form:       form.ID,
+		Submission: submission.ID,
+		Values:     submission.Values,
+	})
+	if err != nil {
+		log.Warnf(ctx, "Could not notify listeners about submission %s: %v", submission.ID, err)
+	}
+	return submission, nil
+}
+
+func (s *Submissions) append(id string, bytes []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := os.MkdirAll(s.directory, 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(bytes)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Fetch all submissions of the supplied form, oldest first
+func (s *Submissions) List(id string) ([]*Submission, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	file, err := os.Open(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Submission{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	result := []*Submission{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var submission Submission
+		if err := json.Unmarshal(line, &submission); err != nil {
+			return nil, NewLoadError("Could not parse submission of form: '%s' because: %v", id, err)
+		}
+		result = append(result, &submission)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Write the supplied submissions as CSV. The columns are the ID, the time of the submission and then one column
+// for each field in the form
+func WriteCSV(w io.Writer, form *Definition, submissions []*Submission) error {
+	writer := csv.NewWriter(w)
+	header := []string{"ID", "CreatedAt"}
+	for _, f := range form.Fields {
+		header = append(header, f.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, submission := range submissions {
+		record := []string{submission.ID, submission.CreatedAt.Format(time.RFC3339)}
+		for _, f := range form.Fields {
+			record = append(record, escapeCSV(submission.Values[f.Name]))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Prevent values from being interpreted as formulas when the CSV is opened in a spreadsheet application
+func escapeCSV(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Create a new store that saves submissions in the supplied directory
+func NewSubmissions(bus *event.Bus, directory string) *Submissions {
+	return &Submissions{
+		bus:       bus,
+		directory: directory,
+		mux:       sync.Mutex{},
+	}
+}
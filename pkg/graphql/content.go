@@ -0,0 +1,563 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"github.com/westcoastcode-se/gocms/pkg/security/jwt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the struct tag used to mark a field as a reference to another page. The field must be a string, or a
+// slice of strings, containing the ID of the referenced pages. For example:
+//
+//	Author string `gocms:"ref=models.Author"`
+//
+// If no model type is specified, the reference resolves to the Page interface.
+const TagName = "gocms"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var Order = &Type{
+	Kind:   Enum,
+	Name:   "Order",
+	Values: []string{"ASC", "DESC"},
+}
+
+// Service that executes GraphQL queries against the content repository. The schema is generated from the
+// model types registered in the repository the first time it's used, which means that all model types must be
+// registered before the first query is executed.
+type ContentService struct {
+	repository content.Repository
+	acl        acl.Service
+	config     config.GraphQLConfig
+	once       sync.Once
+	schema     *Schema
+}
+
+// Fetch the generated schema
+func (c *ContentService) Schema() *Schema {
+	c.once.Do(func() {
+		c.schema = (&schemaBuilder{
+			repository: c.repository,
+			acl:        c.acl,
+			objects:    make(map[reflect.Type]*Type),
+			pages:      make(map[string]*Type),
+		}).build()
+		c.schema.MaxDepth = c.config.MaxDepth
+		c.schema.MaxComplexity = c.config.MaxComplexity
+	})
+	return c.schema
+}
+
+// Execute the supplied request. The user found in the context is used to decide what content is accessible
+func (c *ContentService) Execute(ctx context.Context, request *Request) *Response {
+	return Execute(ctx, c.Schema(), request)
+}
+
+type schemaBuilder struct {
+	repository content.Repository
+	acl        acl.Service
+	types      []*Type
+	objects    map[reflect.Type]*Type
+	pages      map[string]*Type
+	page       *Type
+}
+
+func (b *schemaBuilder) build() *Schema {
+	meta := b.objectType(reflect.TypeOf(content.Meta{}), "Meta")
+	b.page = &Type{
+		Kind:        Interface,
+		Name:        "Page",
+		Description: "A page managed by the content repository",
+		Fields:      b.pageFields(meta),
+		ResolveType: func(value interface{}) *Type {
+			if result, ok := value.(*content.SearchResult); ok {
+				if t, ok := b.pages[result.Model.Type]; ok {
+					return t
+				}
+			}
+			return b.pages[""]
+		},
+	}
+
+	// Pages without a registered model type, or without any content at all, are represented by a generic type
+	b.pages[""] = &Type{
+		Kind:        Object,
+		Name:        "GenericPage",
+		Description: "A page without a registered model type",
+		Fields:      append(b.pageFields(meta), &FieldDefinition{Name: "Content", Type: JSON, Resolve: resolveContent}),
+		Interfaces:  []*Type{b.page},
+	}
+
+	modelTypes := b.repository.GetModelTypes()
+	var names []string
+	for name := range modelTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Register all page types before their fields are created, so that references between them can be resolved
+	for _, name := range names {
+		b.pages[name] = &Type{
+			Kind:        Object,
+			Name:        b.uniqueName(typeName(name)),
+			Description: "A page with model type " + name,
+			Interfaces:  []*Type{b.page},
+		}
+	}
+
+	query := &Type{Kind: Object, Name: "Query"}
+	query.Fields = append(query.Fields, b.findField("page", ""), b.listField("pages", ""))
+	for _, name := range names {
+		t := b.pages[name]
+		t.Fields = b.pageFields(meta)
+		t.Fields = append(t.Fields, b.fields(modelTypes[name], t.Name, resolveContent)...)
+
+		fieldName := strings.ToLower(t.Name[:1]) + t.Name[1:]
+		query.Fields = append(query.Fields, b.findField(fieldName, name), b.listField(fieldName+"List", name))
+	}
+
+	types := []*Type{JSON, Order, b.page, b.pages[""]}
+	types = append(types, b.types...)
+	for _, name := range names {
+		types = append(types, b.pages[name])
+	}
+	return &Schema{Query: query, Types: types}
+}
+
+// Fields that are available for all pages
+func (b *schemaBuilder) pageFields(meta *Type) []*FieldDefinition {
+	model := func(fn func(result *content.SearchResult) interface{}) ResolveFunc {
+		return func(p ResolveParams) (interface{}, error) {
+			return fn(p.Source.(*content.SearchResult)), nil
+		}
+	}
+
+	return []*FieldDefinition{
+		{Name: "ID", Type: String, Resolve: model(func(r *content.SearchResult) interface{} { return r.Model.ID })},
+		{Name: "Path", Type: String, Resolve: model(func(r *content.SearchResult) interface{} { return r.Path })},
+		{Name: "Type", Type: String, Resolve: model(func(r *content.SearchResult) interface{} { return r.Model.Type })},
+		{Name: "View", Type: String, Resolve: model(func(r *content.SearchResult) interface{} { return r.Model.View })},
+		{Name: "CreatedAt", Type: String,
+			Resolve: model(func(r *content.SearchResult) interface{} { return r.Model.CreatedAt })},
+		{Name: "Meta", Type: meta, Resolve: model(func(r *content.SearchResult) interface{} { return r.Model.Meta })},
+	}
+}
+
+// Root field used for finding a single page
+func (b *schemaBuilder) findField(name string, modelType string) *FieldDefinition {
+	return &FieldDefinition{
+		Name:        name,
+		Description: "Find a page using either its path or its ID",
+		Type:        b.pageType(modelType),
+		Arguments: []*ArgumentDefinition{
+			{Name: "path", Type: String},
+			{Name: "id", Type: String},
+		},
+		Resolve: func(p ResolveParams) (interface{}, error) {
+			var result *content.SearchResult
+			if path, ok := p.Args["path"].(string); ok {
+				if model, err := b.repository.FindByPath(path); err == nil {
+					result = &content.SearchResult{Path: path, Model: model}
+				}
+			} else if id, ok := p.Args["id"].(string); ok {
+				result = b.repository.Lookup(id)
+			} else {
+				return nil, errors.New("either path or id is required")
+			}
+
+			if result == nil || !b.isAccessible(p.Context, result) {
+				return nil, nil
+			}
+			if modelType != "" && result.Model.Type != modelType {
+				return nil, nil
+			}
+			return result, nil
+		},
+	}
+}
+
+// Root field used for listing pages
+func (b *schemaBuilder) listField(name string, modelType string) *FieldDefinition {
+	arguments := []*ArgumentDefinition{
+		{Name: "where", Type: JSON},
+		{Name: "sort", Type: String},
+		{Name: "order", Type: Order},
+		{Name: "limit", Type: Int},
+		{Name: "offset", Type: Int},
+	}
+	if modelType == "" {
+		arguments = append([]*ArgumentDefinition{{Name: "type", Type: String}}, arguments...)
+	}
+
+	return &FieldDefinition{
+		Name: name,
+		Description: "List pages. Pages are filtered by the fields in where, sorted by the field in sort " +
+			"and paginated with limit and offset",
+		Type:      ListOf(b.pageType(modelType)),
+		Arguments: arguments,
+		Resolve: func(p ResolveParams) (interface{}, error) {
+			t := modelType
+			if value, ok := p.Args["type"].(string); ok {
+				t = value
+			}
+
+			var results []*content.SearchResult
+			if t != "" {
+				results = b.repository.Search(t)
+			} else {
+				results = b.repository.GetAll()
+			}
+
+			var conditions map[string]interface{}
+			if where, ok := p.Args["where"]; ok {
+				if conditions, ok = where.(map[string]interface{}); !ok {
+					return nil, errors.New("where must be an object")
+				}
+			}
+
+			var filtered []*content.SearchResult
+			for _, result := range results {
+				if b.isAccessible(p.Context, result) && matches(result.Model, "", conditions) {
+					filtered = append(filtered, result)
+				}
+			}
+
+			sortBy, _ := p.Args["sort"].(string)
+			order, _ := p.Args["order"].(string)
+			sortResults(filtered, sortBy, order == "DESC")
+
+			offset, _ := toInt(p.Args["offset"])
+			if offset >= len(filtered) {
+				return []*content.SearchResult{}, nil
+			}
+			if offset > 0 {
+				filtered = filtered[offset:]
+			}
+			if limit, ok := toInt(p.Args["limit"]); ok && limit >= 0 && limit < len(filtered) {
+				filtered = filtered[:limit]
+			}
+			return filtered, nil
+		},
+	}
+}
+
+func (b *schemaBuilder) pageType(modelType string) *Type {
+	if modelType == "" {
+		return b.page
+	}
+	return b.pages[modelType]
+}
+
+// Check to see if the user, found in the supplied context, is allowed to read the supplied page
+func (b *schemaBuilder) isAccessible(ctx context.Context, result *content.SearchResult) bool {
+	user, ok := ctx.Value(jwt.SessionKey).(*security.User)
+	if !ok {
+		user = security.NotLoggedInUser
+	}
+	return acl.IsAccessible(b.acl, user, result.Path)
+}
+
+// Create an object type for the supplied struct type
+func (b *schemaBuilder) objectType(t reflect.Type, name string) *Type {
+	if result, ok := b.objects[t]; ok {
+		return result
+	}
+
+	if t.Name() != "" {
+		name = typeName(t.Name())
+	}
+	result := &Type{Kind: Object, Name: b.uniqueName(name)}
+	b.objects[t] = result
+	b.types = append(b.types, result)
+	result.Fields = b.fields(t, result.Name, nil)
+	return result
+}
+
+// Make sure that the supplied type name isn't used by any other type
+func (b *schemaBuilder) uniqueName(name string) string {
+	used := func(n string) bool {
+		for _, t := range b.types {
+			if t.Name == n {
+				return true
+			}
+		}
+		for _, t := range b.pages {
+			if t.Name == n {
+				return true
+			}
+		}
+		return n == "Page" || n == "Query" || n == "Order" || n == "JSON"
+	}
+
+	result := name
+	for i := 2; used(result); i++ {
+		result = fmt.Sprintf("%s%d", name, i)
+	}
+	return result
+}
+
+// Create field definitions for all exported fields in the supplied struct type. The supplied source function
+// is used to figure out the struct value from the value of the parent object.
+func (b *schemaBuilder) fields(t reflect.Type, owner string, source ResolveFunc) []*FieldDefinition {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var result []*FieldDefinition
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue
+		}
+
+		var fieldType *Type
+		var resolve ResolveFunc
+		if ref, ok := f.Tag.Lookup(TagName); ok && strings.HasPrefix(ref, "ref") {
+			fieldType, resolve = b.referenceField(f, strings.TrimPrefix(strings.TrimPrefix(ref, "ref"), "="))
+		} else {
+			fieldType = b.typeOf(f.Type, owner+f.Name)
+			name := f.Name
+			resolve = func(p ResolveParams) (interface{}, error) {
+				return defaultResolve(p.Source, name), nil
+			}
+		}
+		if fieldType == nil {
+			continue
+		}
+
+		if source != nil {
+			resolve = chain(source, resolve)
+		}
+		result = append(result, &FieldDefinition{Name: f.Name, Type: fieldType, Resolve: resolve})
+	}
+	return result
+}
+
+// Create a field that resolves the ID of a page, or a list of IDs, into the referenced pages
+func (b *schemaBuilder) referenceField(f reflect.StructField, modelType string) (*Type, ResolveFunc) {
+	t := b.page
+	if modelType != "" {
+		if page, ok := b.pages[modelType]; ok {
+			t = page
+		}
+	}
+
+	name := f.Name
+	lookup := func(ctx context.Context, value interface{}) *content.SearchResult {
+		id, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		result := b.repository.Lookup(id)
+		if result == nil || !b.isAccessible(ctx, result) {
+			return nil
+		}
+		if modelType != "" && result.Model.Type != modelType {
+			return nil
+		}
+		return result
+	}
+
+	if f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Array {
+		return ListOf(t), func(p ResolveParams) (interface{}, error) {
+			v := reflect.ValueOf(defaultResolve(p.Source, name))
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				return nil, nil
+			}
+			var results []*content.SearchResult
+			for i := 0; i < v.Len(); i++ {
+				if result := lookup(p.Context, v.Index(i).Interface()); result != nil {
+					results = append(results, result)
+				}
+			}
+			return results, nil
+		}
+	}
+
+	return t, func(p ResolveParams) (interface{}, error) {
+		if result := lookup(p.Context, defaultResolve(p.Source, name)); result != nil {
+			return result, nil
+		}
+		return nil, nil
+	}
+}
+
+// Figure out the GraphQL type of the supplied Go type
+func (b *schemaBuilder) typeOf(t reflect.Type, name string) *Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return String
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String
+	case reflect.Bool:
+		return Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Int
+	case reflect.Float32, reflect.Float64:
+		return Float
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return String
+		}
+		if elem := b.typeOf(t.Elem(), name); elem != nil {
+			return ListOf(elem)
+		}
+		return nil
+	case reflect.Struct:
+		return b.objectType(t, name)
+	case reflect.Map, reflect.Interface:
+		return JSON
+	}
+	return nil
+}
+
+// Resolve the actual content of a page
+func resolveContent(p ResolveParams) (interface{}, error) {
+	return p.Source.(*content.SearchResult).Model.Content, nil
+}
+
+// Create a resolve function that uses the result of the first function as the source of the second function
+func chain(first ResolveFunc, second ResolveFunc) ResolveFunc {
+	return func(p ResolveParams) (interface{}, error) {
+		source, err := first(p)
+		if err != nil || source == nil {
+			return nil, err
+		}
+		p.Source = source
+		return second(p)
+	}
+}
+
+// Convert a model type name, such as "models.News", into a valid GraphQL type name, such as "News"
+func typeName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var sb strings.Builder
+	for i, c := range name {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
+
+// Check to see if the fields in the supplied model matches all conditions. Nested conditions, such as
+// {Meta: {Title: "Hello"}}, are matched against nested fields.
+func matches(model *content.Model, prefix string, conditions map[string]interface{}) bool {
+	for key, expected := range conditions {
+		if nested, ok := expected.(map[string]interface{}); ok {
+			if !matches(model, prefix+key+".", nested) {
+				return false
+			}
+			continue
+		}
+
+		if compareValues(model.Field(prefix+key), expected) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sortResults(results []*content.SearchResult, field string, descending bool) {
+	if field == "" {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Path < results[j].Path
+		})
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		c := compareValues(results[i].Model.Field(field), results[j].Model.Field(field))
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// Compare two values. Returns a negative number if a is less than b, zero if they are equal and a positive
+// number if a is greater than b
+func compareValues(a interface{}, b interface{}) int {
+	if t, ok := a.(time.Time); ok {
+		if s, ok := b.(string); ok {
+			if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+				b = parsed
+			}
+		}
+		if other, ok := b.(time.Time); ok {
+			switch {
+			case t.Before(other):
+				return -1
+			case t.After(other):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func toInt(value interface{}) (int, bool) {
+	if f, ok := toFloat(value); ok {
+		return int(f), true
+	}
+	return 0, false
+}
+
+// Create a new service used for executing GraphQL queries against the supplied repository
+func NewContentService(repository content.Repository, service acl.Service, config config.GraphQLConfig) *ContentService {
+	return &ContentService{repository: repository, acl: service, config: config}
+}
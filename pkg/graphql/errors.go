@@ -0,0 +1,31 @@
+package graphql
+
+import "fmt"
+
+// Error raised when a query document could not be parsed
+type SyntaxError struct {
+	Position int
+	message  string
+}
+
+func (s *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", s.Position, s.message)
+}
+
+func newSyntaxError(pos int, format string, v ...interface{}) *SyntaxError {
+	return &SyntaxError{Position: pos, message: fmt.Sprintf(format, v...)}
+}
+
+// An error as it's returned to the client in a GraphQL response
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(path []interface{}, format string, v ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, v...), Path: path}
+}
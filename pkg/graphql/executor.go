@@ -0,0 +1,303 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// A request to execute a query
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// The result of an executed query
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// An object in a response. The fields are kept in the order they are selected in the query
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedMap) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type executor struct {
+	ctx       context.Context
+	schema    *Schema
+	document  *Document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// Execute the supplied request. Only queries are supported
+func Execute(ctx context.Context, schema *Schema, request *Request) *Response {
+	document, err := Parse(request.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	var operation *Operation
+	for _, o := range document.Operations {
+		if request.OperationName == "" || o.Name == request.OperationName {
+			if operation != nil {
+				return &Response{Errors: []*Error{{Message: "an operation name is required when the document " +
+					"contains multiple operations"}}}
+			}
+			operation = o
+		}
+	}
+	if operation == nil {
+		return &Response{Errors: []*Error{{Message: "could not find operation: " + request.OperationName}}}
+	}
+	if operation.Type != "query" {
+		return &Response{Errors: []*Error{{Message: "unsupported operation type: " + operation.Type}}}
+	}
+	if err := validate(schema, document, operation); err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+
+	variables := make(map[string]interface{})
+	for _, v := range operation.Variables {
+		if value, ok := request.Variables[v.Name]; ok {
+			variables[v.Name] = value
+		} else if v.DefaultValue != nil {
+			variables[v.Name] = v.DefaultValue
+		}
+	}
+
+	e := &executor{ctx: ctx, schema: schema, document: document, variables: variables}
+	data := e.executeSelectionSet(schema.Query, nil, operation.SelectionSet, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func (e *executor) addError(err *Error) {
+	e.errors = append(e.errors, err)
+}
+
+// Collect all fields selected for the supplied object type, grouped by their response key
+func (e *executor) collectFields(objectType *Type, selectionSet []Selection, keys *[]string,
+	fields map[string][]*Field, visited map[string]bool) {
+	for _, selection := range selectionSet {
+		switch s := selection.(type) {
+		case *Field:
+			key := s.ResponseKey()
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], s)
+		case *InlineFragment:
+			if s.TypeCondition == "" || objectType.Is(s.TypeCondition) {
+				e.collectFields(objectType, s.SelectionSet, keys, fields, visited)
+			}
+		case *FragmentSpread:
+			if visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			fragment, ok := e.document.Fragments[s.Name]
+			if !ok {
+				e.addError(newError(nil, "unknown fragment %q", s.Name))
+				continue
+			}
+			if objectType.Is(fragment.TypeCondition) {
+				e.collectFields(objectType, fragment.SelectionSet, keys, fields, visited)
+			}
+		}
+	}
+}
+
+func (e *executor) executeSelectionSet(objectType *Type, source interface{}, selectionSet []Selection,
+	path []interface{}) *orderedMap {
+	var keys []string
+	fields := make(map[string][]*Field)
+	e.collectFields(objectType, selectionSet, &keys, fields, make(map[string]bool))
+
+	result := &orderedMap{values: make(map[string]interface{})}
+	for _, key := range keys {
+		field := fields[key][0]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		if field.Name == "__typename" {
+			result.set(key, objectType.Name)
+			continue
+		}
+
+		definition := objectType.Field(field.Name)
+		if definition == nil {
+			e.addError(newError(fieldPath, "cannot query field %q on type %q", field.Name, objectType.Name))
+			result.set(key, nil)
+			continue
+		}
+
+		var value interface{}
+		var err error
+		params := ResolveParams{Context: e.ctx, Source: source, Args: e.resolveArguments(field.Arguments)}
+		if definition.Resolve != nil {
+			value, err = definition.Resolve(params)
+		} else {
+			value = defaultResolve(params.Source, field.Name)
+		}
+		if err != nil {
+			e.addError(newError(fieldPath, "%s", err.Error()))
+			result.set(key, nil)
+			continue
+		}
+
+		var selections []Selection
+		for _, f := range fields[key] {
+			selections = append(selections, f.SelectionSet...)
+		}
+		result.set(key, e.completeValue(definition.Type, value, selections, fieldPath))
+	}
+	return result
+}
+
+func (e *executor) completeValue(t *Type, value interface{}, selectionSet []Selection, path []interface{}) interface{} {
+	if isNil(value) {
+		return nil
+	}
+
+	switch t.Kind {
+	case List:
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			e.addError(newError(path, "expected a list"))
+			return nil
+		}
+		result := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result[i] = e.completeValue(t.OfType, v.Index(i).Interface(), selectionSet,
+				append(append([]interface{}{}, path...), i))
+		}
+		return result
+	case Scalar, Enum:
+		if len(selectionSet) > 0 {
+			e.addError(newError(path, "field of type %q must not have a selection", t.Name))
+			return nil
+		}
+		if t.Serialize == nil {
+			return value
+		}
+		return t.Serialize(value)
+	case Interface:
+		objectType := t.ResolveType(value)
+		if objectType == nil {
+			e.addError(newError(path, "could not figure out the type of %q", t.Name))
+			return nil
+		}
+		t = objectType
+	}
+
+	if len(selectionSet) == 0 {
+		e.addError(newError(path, "field of type %q must have a selection of subfields", t.Name))
+		return nil
+	}
+	return e.executeSelectionSet(t, value, selectionSet, path)
+}
+
+// Replace all variables with their values
+func (e *executor) resolveArguments(arguments map[string]Value) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range arguments {
+		if v := e.resolveValue(value); v != nil {
+			result[key] = v
+		}
+	}
+	return result
+}
+
+func (e *executor) resolveValue(value Value) interface{} {
+	switch v := value.(type) {
+	case *Variable:
+		return e.variables[v.Name]
+	case EnumValue:
+		return string(v)
+	case []Value:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = e.resolveValue(item)
+		}
+		return result
+	case map[string]Value:
+		result := make(map[string]interface{})
+		for key, item := range v {
+			result[key] = e.resolveValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+// Resolve the field by using its name on the source value
+func defaultResolve(source interface{}, name string) interface{} {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		v = v.FieldByName(name)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		v = v.MapIndex(reflect.ValueOf(name))
+	default:
+		return nil
+	}
+
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
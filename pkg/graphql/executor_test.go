@@ -0,0 +1,134 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testPage struct {
+	Title string
+	Ref   *testPage
+}
+
+// Create a schema with pages that reference each other
+func newTestSchema(maxDepth, maxComplexity int) *Schema {
+	first := &testPage{Title: "First"}
+	second := &testPage{Title: "Second", Ref: first}
+	first.Ref = second
+
+	page := &Type{Kind: Object, Name: "Page"}
+	page.Fields = []*FieldDefinition{
+		{Name: "Title", Type: String},
+		{Name: "Ref", Type: page},
+	}
+	query := &Type{Kind: Object, Name: "Query", Fields: []*FieldDefinition{
+		{Name: "page", Type: page, Resolve: func(p ResolveParams) (interface{}, error) {
+			return first, nil
+		}},
+	}}
+	return &Schema{Query: query, Types: []*Type{page}, MaxDepth: maxDepth, MaxComplexity: maxComplexity}
+}
+
+func execute(schema *Schema, query string) *Response {
+	return Execute(context.Background(), schema, &Request{Query: query})
+}
+
+func expectError(t *testing.T, response *Response, message string) {
+	t.Helper()
+	if len(response.Errors) != 1 {
+		t.Fatalf("expected one error but got %d", len(response.Errors))
+	}
+	if !strings.Contains(response.Errors[0].Message, message) {
+		t.Fatalf("expected an error containing %q but got %q", message, response.Errors[0].Message)
+	}
+	if response.Data != nil {
+		t.Fatalf("expected no data but got %v", response.Data)
+	}
+}
+
+func TestExecuteWithFragments(t *testing.T) {
+	response := execute(newTestSchema(10, 100), `
+		query { page { ...Fields Ref { ...Fields } } }
+		fragment Fields on Page { Title }`)
+	if len(response.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors[0])
+	}
+	b, err := response.Data.(*orderedMap).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"page":{"Title":"First","Ref":{"Title":"Second"}}}`
+	if string(b) != expected {
+		t.Fatalf("expected %s but got %s", expected, b)
+	}
+}
+
+func TestExecuteRejectsFragmentThatSpreadsItself(t *testing.T) {
+	response := execute(newTestSchema(0, 0), `
+		query { page { ...F } }
+		fragment F on Page { Ref { ...F } }`)
+	expectError(t, response, `fragment "F" must not spread itself`)
+}
+
+func TestExecuteRejectsFragmentCycle(t *testing.T) {
+	response := execute(newTestSchema(0, 0), `
+		query { page { ...A } }
+		fragment A on Page { Title ... on Page { Ref { ...B } } }
+		fragment B on Page { Ref { ...A } }`)
+	expectError(t, response, "must not spread itself")
+}
+
+func TestExecuteRejectsUnusedFragmentCycle(t *testing.T) {
+	response := execute(newTestSchema(0, 0), `
+		query { page { Title } }
+		fragment A on Page { ...B }
+		fragment B on Page { ...A }`)
+	expectError(t, response, "must not spread itself")
+}
+
+func TestExecuteRejectsUnknownFragment(t *testing.T) {
+	response := execute(newTestSchema(0, 0), `query { page { ...Missing } }`)
+	expectError(t, response, `unknown fragment "Missing"`)
+}
+
+func TestExecuteRejectsDeepQuery(t *testing.T) {
+	schema := newTestSchema(3, 0)
+	response := execute(schema, `{ page { Ref { Title } } }`)
+	if len(response.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors[0])
+	}
+
+	response = execute(schema, `
+		{ page { ...F } }
+		fragment F on Page { Ref { Ref { Title } } }`)
+	expectError(t, response, "query is too deep: 4 levels, maximum is 3")
+}
+
+func TestExecuteRejectsExponentialFragments(t *testing.T) {
+	// Each fragment selects the next fragment twice, which means that the query selects 2^40 fields
+	var sb strings.Builder
+	sb.WriteString("{ page { ...F0 } }\n")
+	for i := 0; i < 40; i++ {
+		_, _ = fmt.Fprintf(&sb, "fragment F%d on Page { a: Ref { ...F%d } b: Ref { ...F%d } }\n", i, i+1, i+1)
+	}
+	sb.WriteString("fragment F40 on Page { Title }\n")
+
+	response := execute(newTestSchema(0, 1000), sb.String())
+	expectError(t, response, "query is too complex")
+}
+
+func TestParseRejectsDeeplyNestedDocument(t *testing.T) {
+	query := strings.Repeat("{ page ", 10000) + strings.Repeat("}", 10000)
+	_, err := Parse(query)
+	if err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Fatalf("expected a nesting error but got %v", err)
+	}
+
+	value := "{ page(filter: " + strings.Repeat("[", 10000) + strings.Repeat("]", 10000) + ") { Title } }"
+	_, err = Parse(value)
+	if err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Fatalf("expected a nesting error but got %v", err)
+	}
+}
@@ -0,0 +1,522 @@
+package graphql
+
+import (
+	"strconv"
+)
+
+// A parsed query document
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// An operation, such as a query, found in a document
+type Operation struct {
+	Type         string
+	Name         string
+	Variables    []*VariableDefinition
+	SelectionSet []Selection
+}
+
+// Definition of a variable used by an operation
+type VariableDefinition struct {
+	Name         string
+	DefaultValue Value
+}
+
+// A named fragment that can be spread into selection sets
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	SelectionSet  []Selection
+}
+
+// Either a *Field, a *FragmentSpread or an *InlineFragment
+type Selection interface{}
+
+// A field selected in a selection set
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    map[string]Value
+	SelectionSet []Selection
+}
+
+// The key used for the field in the response
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// A named fragment spread into a selection set, for example "...NewsFields"
+type FragmentSpread struct {
+	Name string
+}
+
+// A fragment defined directly in a selection set, for example "... on News { Headline }"
+type InlineFragment struct {
+	TypeCondition string
+	SelectionSet  []Selection
+}
+
+// Either a literal value or a *Variable
+type Value interface{}
+
+// A reference to a variable, for example "$path"
+type Variable struct {
+	Name string
+}
+
+// An enum value, for example DESC
+type EnumValue string
+
+// The maximum number of nested selection sets and values. Stops documents that are nested deep enough to exhaust
+// the stack from being parsed
+const maxNesting = 64
+
+type parser struct {
+	lexer   *lexer
+	current token
+	nesting int
+}
+
+// Parse the supplied query document
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: &lexer{source: source}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	document := &Document{Fragments: make(map[string]*Fragment)}
+	for p.current.kind != tokenEOF {
+		if p.peek("{") {
+			selectionSet, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			document.Operations = append(document.Operations, &Operation{Type: "query", SelectionSet: selectionSet})
+			continue
+		}
+
+		if p.current.kind != tokenName {
+			return nil, p.unexpected()
+		}
+
+		switch p.current.value {
+		case "query", "mutation", "subscription":
+			operation, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			document.Operations = append(document.Operations, operation)
+		case "fragment":
+			fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			document.Fragments[fragment.Name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	return document, nil
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.current = t
+	return nil
+}
+
+func (p *parser) peek(punctuator string) bool {
+	return p.current.kind == tokenPunctuator && p.current.value == punctuator
+}
+
+func (p *parser) unexpected() error {
+	if p.current.kind == tokenEOF {
+		return newSyntaxError(p.current.pos, "unexpected end of document")
+	}
+	return newSyntaxError(p.current.pos, "unexpected %q", p.current.value)
+}
+
+func (p *parser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.current.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.current.value
+	return name, p.advance()
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if p.current.kind != tokenName || p.current.value != keyword {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	operation := &Operation{Type: p.current.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.kind == tokenName {
+		operation.Name = p.current.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		variables, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		operation.Variables = variables
+	}
+
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+
+	selectionSet, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.SelectionSet = selectionSet
+	return operation, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*VariableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var result []*VariableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+
+		definition := &VariableDefinition{Name: name}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			value, err := p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+			definition.DefaultValue = value
+		}
+		result = append(result, definition)
+	}
+	return result, p.advance()
+}
+
+// Types are not validated when executing a query, so they are only parsed and then ignored
+func (p *parser) skipType() error {
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+
+	if p.peek("!") {
+		return p.advance()
+	}
+	return nil
+}
+
+// Directives are not supported, so they are parsed and then ignored
+func (p *parser) skipDirectives() error {
+	for p.peek("@") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+		if p.peek("(") {
+			if _, err := p.parseArguments(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseFragment() (*Fragment, error) {
+	if err := p.expectKeyword("fragment"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+	selectionSet, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: typeCondition, SelectionSet: selectionSet}, nil
+}
+
+// Enter a nested selection set or value. Returns an error if the document is nested too deep
+func (p *parser) enter() error {
+	p.nesting++
+	if p.nesting > maxNesting {
+		return newSyntaxError(p.current.pos, "document is nested more than %d levels", maxNesting)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.nesting--
+}
+
+func (p *parser) parseSelectionSet() ([]Selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var result []Selection
+	for !p.peek("}") {
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, selection)
+	}
+	return result, p.advance()
+}
+
+func (p *parser) parseSelection() (Selection, error) {
+	if !p.peek("...") {
+		return p.parseField()
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.kind == tokenName && p.current.value != "on" {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		return &FragmentSpread{Name: name}, p.skipDirectives()
+	}
+
+	fragment := &InlineFragment{}
+	if p.current.kind == tokenName {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		typeCondition, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		fragment.TypeCondition = typeCondition
+	}
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+	selectionSet, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	fragment.SelectionSet = selectionSet
+	return fragment, nil
+}
+
+func (p *parser) parseField() (*Field, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	field := &Field{Name: name}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if field.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if field.Arguments, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.skipDirectives(); err != nil {
+		return nil, err
+	}
+
+	if p.peek("{") {
+		if field.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseArguments() (map[string]Value, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Value)
+	for !p.peek(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, p.advance()
+}
+
+func (p *parser) parseValue(constant bool) (Value, error) {
+	t := p.current
+	switch t.kind {
+	case tokenInt:
+		value, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, newSyntaxError(t.pos, "invalid integer %s", t.value)
+		}
+		return value, p.advance()
+	case tokenFloat:
+		value, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, newSyntaxError(t.pos, "invalid float %s", t.value)
+		}
+		return value, p.advance()
+	case tokenString:
+		return t.value, p.advance()
+	case tokenName:
+		var value Value
+		switch t.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = EnumValue(t.value)
+		}
+		return value, p.advance()
+	case tokenPunctuator:
+		switch t.value {
+		case "$":
+			if constant {
+				return nil, p.unexpected()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return &Variable{Name: name}, nil
+		case "[":
+			if err := p.enter(); err != nil {
+				return nil, err
+			}
+			defer p.leave()
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			result := []Value{}
+			for !p.peek("]") {
+				value, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, value)
+			}
+			return result, p.advance()
+		case "{":
+			if err := p.enter(); err != nil {
+				return nil, err
+			}
+			defer p.leave()
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			result := make(map[string]Value)
+			for !p.peek("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				value, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				result[name] = value
+			}
+			return result, p.advance()
+		}
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,242 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Kind int
+
+const (
+	Scalar Kind = iota
+	Object
+	Interface
+	Enum
+	List
+)
+
+// Function used for resolving the value of a field
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// Parameters sent to a ResolveFunc
+type ResolveParams struct {
+	// The context of the request
+	Context context.Context
+	// The value of the parent object
+	Source interface{}
+	// All arguments sent to the field
+	Args map[string]interface{}
+}
+
+// A type in a schema
+type Type struct {
+	Kind        Kind
+	Name        string
+	Description string
+
+	// The type of the items in a list
+	OfType *Type
+
+	// Fields of objects and interfaces
+	Fields []*FieldDefinition
+
+	// Interfaces implemented by an object
+	Interfaces []*Type
+
+	// Values of an enum
+	Values []string
+
+	// Figure out the object type of a value with an interface type
+	ResolveType func(value interface{}) *Type
+
+	// Convert a value with a scalar type into a value that can be marshalled into JSON
+	Serialize func(value interface{}) interface{}
+}
+
+// Search for a field with the supplied name. Returns nil if the field doesn't exist
+func (t *Type) Field(name string) *FieldDefinition {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Check to see if this type is, or implements, the type with the supplied name
+func (t *Type) Is(name string) bool {
+	if t.Name == name {
+		return true
+	}
+	for _, i := range t.Interfaces {
+		if i.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The name of this type as used when referring to it, for example "[News]"
+func (t *Type) String() string {
+	if t.Kind == List {
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// Create a list type of the supplied type
+func ListOf(t *Type) *Type {
+	return &Type{Kind: List, OfType: t}
+}
+
+// A field of an object or an interface
+type FieldDefinition struct {
+	Name        string
+	Description string
+	Type        *Type
+	Arguments   []*ArgumentDefinition
+	Resolve     ResolveFunc
+}
+
+// An argument accepted by a field
+type ArgumentDefinition struct {
+	Name string
+	Type *Type
+}
+
+// The schema of a GraphQL service
+type Schema struct {
+	// The root type of all queries
+	Query *Type
+	// All named types, in the order they are printed
+	Types []*Type
+	// The maximum number of nested fields in a query. No limit if zero
+	MaxDepth int
+	// The maximum number of fields in a query, after all fragments are spread. No limit if zero
+	MaxComplexity int
+}
+
+// Print the schema using the schema definition language
+func (s *Schema) String() string {
+	var sb strings.Builder
+	for _, t := range append(s.Types, s.Query) {
+		if t.Description != "" {
+			_, _ = fmt.Fprintf(&sb, "\"\"\"%s\"\"\"\n", t.Description)
+		}
+
+		switch t.Kind {
+		case Scalar:
+			_, _ = fmt.Fprintf(&sb, "scalar %s\n\n", t.Name)
+			continue
+		case Enum:
+			_, _ = fmt.Fprintf(&sb, "enum %s {\n", t.Name)
+			for _, v := range t.Values {
+				_, _ = fmt.Fprintf(&sb, "  %s\n", v)
+			}
+			sb.WriteString("}\n\n")
+			continue
+		case Interface:
+			_, _ = fmt.Fprintf(&sb, "interface %s {\n", t.Name)
+		default:
+			_, _ = fmt.Fprintf(&sb, "type %s", t.Name)
+			for i, iface := range t.Interfaces {
+				if i == 0 {
+					sb.WriteString(" implements ")
+				} else {
+					sb.WriteString(" & ")
+				}
+				sb.WriteString(iface.Name)
+			}
+			sb.WriteString(" {\n")
+		}
+
+		for _, f := range t.Fields {
+			if f.Description != "" {
+				_, _ = fmt.Fprintf(&sb, "  \"%s\"\n", f.Description)
+			}
+			sb.WriteString("  " + f.Name)
+			if len(f.Arguments) > 0 {
+				var args []string
+				for _, a := range f.Arguments {
+					args = append(args, a.Name+": "+a.Type.String())
+				}
+				sb.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			sb.WriteString(": " + f.Type.String() + "\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+	return strings.TrimSpace(sb.String()) + "\n"
+}
+
+var String = &Type{
+	Kind: Scalar,
+	Name: "String",
+	Serialize: func(value interface{}) interface{} {
+		if t, ok := value.(time.Time); ok {
+			return t.Format(time.RFC3339Nano)
+		}
+		if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+			return v.String()
+		}
+		return fmt.Sprint(value)
+	},
+}
+
+var Int = &Type{
+	Kind: Scalar,
+	Name: "Int",
+	Serialize: func(value interface{}) interface{} {
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return int64(v.Float())
+		}
+		return nil
+	},
+}
+
+var Float = &Type{
+	Kind: Scalar,
+	Name: "Float",
+	Serialize: func(value interface{}) interface{} {
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			return v.Float()
+		}
+		return nil
+	},
+}
+
+var Boolean = &Type{
+	Kind: Scalar,
+	Name: "Boolean",
+	Serialize: func(value interface{}) interface{} {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Bool {
+			return v.Bool()
+		}
+		return nil
+	},
+}
+
+// Scalar for values without a known structure. The value is returned as-is
+var JSON = &Type{
+	Kind:        Scalar,
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+}
@@ -0,0 +1,104 @@
+package graphql
+
+import "math"
+
+// Validates an operation before it's executed
+type validator struct {
+	document *Document
+	// Fragments that are currently being visited. Used for finding fragments that spread themselves
+	visiting map[string]bool
+	// The depth of fragments that are already validated
+	depths map[string]int
+	// The complexity of fragments that are already validated
+	complexities map[string]int
+}
+
+// Add two complexities without overflowing. Fragments that are spread multiple times into each other can
+// produce complexities that are too large for an int
+func addComplexity(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+// Figure out the depth and the complexity of the supplied selection set. The depth is the number of nested fields
+// and the complexity is the number of fields that are selected when all fragments are spread
+func (v *validator) selectionSet(selectionSet []Selection) (int, int, *Error) {
+	depth, complexity := 0, 0
+	for _, selection := range selectionSet {
+		var d, c int
+		var err *Error
+		switch s := selection.(type) {
+		case *Field:
+			d, c, err = v.selectionSet(s.SelectionSet)
+			d, c = d+1, addComplexity(c, 1)
+		case *InlineFragment:
+			d, c, err = v.selectionSet(s.SelectionSet)
+		case *FragmentSpread:
+			d, c, err = v.fragment(s.Name)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity = addComplexity(complexity, c)
+	}
+	return depth, complexity, nil
+}
+
+// Figure out the depth and the complexity of the fragment with the supplied name. Each fragment is only visited
+// once, which means that fragments spread multiple times into each other don't result in exponential work
+func (v *validator) fragment(name string) (int, int, *Error) {
+	if depth, ok := v.depths[name]; ok {
+		return depth, v.complexities[name], nil
+	}
+
+	fragment, ok := v.document.Fragments[name]
+	if !ok {
+		return 0, 0, newError(nil, "unknown fragment %q", name)
+	}
+	if v.visiting[name] {
+		return 0, 0, newError(nil, "fragment %q must not spread itself", name)
+	}
+
+	v.visiting[name] = true
+	depth, complexity, err := v.selectionSet(fragment.SelectionSet)
+	delete(v.visiting, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	v.depths[name] = depth
+	v.complexities[name] = complexity
+	return depth, complexity, nil
+}
+
+// Validate the supplied operation before it's executed. No fragment in the document is allowed to spread itself,
+// directly or through other fragments, and the operation must not be deeper or more complex than the schema allows
+func validate(schema *Schema, document *Document, operation *Operation) *Error {
+	v := &validator{
+		document:     document,
+		visiting:     make(map[string]bool),
+		depths:       make(map[string]int),
+		complexities: make(map[string]int),
+	}
+	for name := range document.Fragments {
+		if _, _, err := v.fragment(name); err != nil {
+			return err
+		}
+	}
+
+	depth, complexity, err := v.selectionSet(operation.SelectionSet)
+	if err != nil {
+		return err
+	}
+	if schema.MaxDepth > 0 && depth > schema.MaxDepth {
+		return newError(nil, "query is too deep: %d levels, maximum is %d", depth, schema.MaxDepth)
+	}
+	if schema.MaxComplexity > 0 && complexity > schema.MaxComplexity {
+		return newError(nil, "query is too complex: %d fields, maximum is %d", complexity, schema.MaxComplexity)
+	}
+	return nil
+}
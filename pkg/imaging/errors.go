@@ -0,0 +1,30 @@
+package imaging
+
+import "fmt"
+
+// Error raised when the requested size, fit or format isn't allowed
+type InvalidOptionsError struct {
+	message string
+}
+
+func (i *InvalidOptionsError) Error() string {
+	return i.message
+}
+
+func NewInvalidOptionsError(format string, v ...interface{}) *InvalidOptionsError {
+	return &InvalidOptionsError{
+		message: fmt.Sprintf(format, v...),
+	}
+}
+
+// Error raised when a file can't be resized, for example when it isn't an image
+type UnsupportedImageError struct {
+	// The path of the image
+	Path string
+	// Why the image isn't supported
+	Reason string
+}
+
+func (u *UnsupportedImageError) Error() string {
+	return fmt.Sprintf("image '%s' is not supported: %s", u.Path, u.Reason)
+}
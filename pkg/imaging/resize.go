@@ -0,0 +1,200 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// How an image is fitted into the requested size
+const (
+	// Scale the image so that it covers the requested size and crop what's outside of it
+	Cover = "cover"
+	// Scale the image so that it fits inside the requested size
+	Contain = "contain"
+	// Stretch the image to the requested size
+	Fill = "fill"
+)
+
+// Formats an image can be converted to
+const (
+	JPEG = "jpeg"
+	PNG  = "png"
+	GIF  = "gif"
+)
+
+// Options used when resizing an image
+type Options struct {
+	// The requested width. Zero if the width should be calculated from the height
+	Width int
+	// The requested height. Zero if the height should be calculated from the width
+	Height int
+	// How the image is fitted into the requested size, for example "cover"
+	Fit string
+	// The format of the resized image. The format of the original image is used if empty
+	Format string
+}
+
+// The source pixels, and their weights, that make up a destination pixel
+type contribution struct {
+	start   int
+	weights []float64
+}
+
+// Figure out which source pixels make up each destination pixel when a row, or column, of srcLen pixels is
+// scaled to dstLen pixels. Each destination pixel is the average of the source pixels it covers
+func contributions(srcLen, dstLen int) []contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	result := make([]contribution, dstLen)
+	for i := range result {
+		begin := float64(i) * scale
+		end := begin + scale
+		first := int(math.Floor(begin))
+		last := int(math.Ceil(end))
+		if last > srcLen {
+			last = srcLen
+		}
+		if last <= first {
+			last = first + 1
+		}
+
+		weights := make([]float64, last-first)
+		sum := 0.0
+		for j := first; j < last; j++ {
+			w := math.Min(end, float64(j+1)) - math.Max(begin, float64(j))
+			if w <= 0 {
+				w = 0
+			}
+			weights[j-first] = w
+			sum += w
+		}
+		for j := range weights {
+			if sum > 0 {
+				weights[j] /= sum
+			} else {
+				weights[j] = 1 / float64(len(weights))
+			}
+		}
+		result[i] = contribution{start: first, weights: weights}
+	}
+	return result
+}
+
+func clamp(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// Scale the lines of an image in one direction. The offset functions figure out where the pixel at a position in a
+// line is located, which means that the same function is used for both rows and columns
+func scaleLines(src []uint8, srcOffset func(line, i int) int, dst []uint8, dstOffset func(line, i int) int,
+	lines int, cs []contribution) {
+	for line := 0; line < lines; line++ {
+		for i, c := range cs {
+			var r, g, b, a float64
+			for k, w := range c.weights {
+				o := srcOffset(line, c.start+k)
+				r += w * float64(src[o])
+				g += w * float64(src[o+1])
+				b += w * float64(src[o+2])
+				a += w * float64(src[o+3])
+			}
+			o := dstOffset(line, i)
+			dst[o] = clamp(r)
+			dst[o+1] = clamp(g)
+			dst[o+2] = clamp(b)
+			dst[o+3] = clamp(a)
+		}
+	}
+}
+
+// Scale the supplied image to the supplied size. Rows are scaled first and then columns. The pixels are
+// premultiplied with alpha, which means that transparent pixels don't bleed into their neighbours
+func scale(src *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	tmp := image.NewRGBA(image.Rect(0, 0, width, srcHeight))
+	scaleLines(src.Pix, func(line, i int) int { return line*src.Stride + i*4 },
+		tmp.Pix, func(line, i int) int { return line*tmp.Stride + i*4 },
+		srcHeight, contributions(srcWidth, width))
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleLines(tmp.Pix, func(line, i int) int { return i*tmp.Stride + line*4 },
+		dst.Pix, func(line, i int) int { return i*dst.Stride + line*4 },
+		width, contributions(srcHeight, height))
+	return dst
+}
+
+func round(v float64) int {
+	result := int(v + 0.5)
+	if result < 1 {
+		return 1
+	}
+	return result
+}
+
+// Figure out what part of an image of the supplied size to use and the size of the resized image. Images are
+// never scaled up
+func geometry(srcWidth, srcHeight int, o Options) (image.Rectangle, int, int) {
+	all := image.Rect(0, 0, srcWidth, srcHeight)
+	width, height := o.Width, o.Height
+	if height == 0 {
+		if width > srcWidth {
+			width = srcWidth
+		}
+		return all, width, round(float64(width) * float64(srcHeight) / float64(srcWidth))
+	}
+	if width == 0 {
+		if height > srcHeight {
+			height = srcHeight
+		}
+		return all, round(float64(height) * float64(srcWidth) / float64(srcHeight)), height
+	}
+
+	switch o.Fit {
+	case Contain:
+		factor := math.Min(math.Min(float64(width)/float64(srcWidth), float64(height)/float64(srcHeight)), 1)
+		return all, round(float64(srcWidth) * factor), round(float64(srcHeight) * factor)
+	case Fill:
+		if width > srcWidth {
+			width = srcWidth
+		}
+		if height > srcHeight {
+			height = srcHeight
+		}
+		return all, width, height
+	}
+
+	// Crop the center of the image so that it has the same aspect ratio as the requested size
+	cropWidth, cropHeight := srcWidth, srcHeight
+	if srcWidth*height > srcHeight*width {
+		cropWidth = round(float64(srcHeight) * float64(width) / float64(height))
+	} else {
+		cropHeight = round(float64(srcWidth) * float64(height) / float64(width))
+	}
+	x := (srcWidth - cropWidth) / 2
+	y := (srcHeight - cropHeight) / 2
+	crop := image.Rect(x, y, x+cropWidth, y+cropHeight)
+	if width > cropWidth {
+		width, height = cropWidth, cropHeight
+	}
+	return crop, width, height
+}
+
+// Resize the supplied image using the supplied options
+func Resize(src image.Image, o Options) *image.RGBA {
+	bounds := src.Bounds()
+	crop, width, height := geometry(bounds.Dx(), bounds.Dy(), o)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, crop.Min.Add(bounds.Min), draw.Src)
+	if crop.Dx() == width && crop.Dy() == height {
+		return rgba
+	}
+	return scale(rgba, width, height)
+}
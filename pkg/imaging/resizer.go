@@ -0,0 +1,236 @@
+package imaging
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The maximum number of pixels in an image that can be resized. Protects against images that decode into
+// huge amounts of memory
+const maxPixels = 50000000
+
+// File extensions used for each format
+var extensions = map[string]string{
+	JPEG: ".jpg",
+	PNG:  ".png",
+	GIF:  ".gif",
+}
+
+// Service that resizes images found among the static files. Resized images are cached on disk, which means that
+// each size of an image is only resized once
+type Resizer struct {
+	// The quality, between 1 and 100, used when encoding JPEG images
+	Quality int
+
+	root         string
+	staticPrefix string
+	uriPrefix    string
+	directory    string
+	sizes        []config.ImageSizeConfig
+	presets      map[string]config.ImagePresetConfig
+	// Limits the number of images being resized at the same time
+	sem chan struct{}
+}
+
+// Parse the resize options found in the supplied query, for example "?w=800&h=600&fit=cover". Only sizes that are
+// allow-listed in the configuration are accepted
+func (r *Resizer) ParseOptions(query url.Values) (Options, error) {
+	var o Options
+	var err error
+	if w := query.Get("w"); w != "" {
+		if o.Width, err = strconv.Atoi(w); err != nil || o.Width < 0 {
+			return o, NewInvalidOptionsError("invalid width: %s", w)
+		}
+	}
+	if h := query.Get("h"); h != "" {
+		if o.Height, err = strconv.Atoi(h); err != nil || o.Height < 0 {
+			return o, NewInvalidOptionsError("invalid height: %s", h)
+		}
+	}
+	if o.Width == 0 && o.Height == 0 {
+		return o, NewInvalidOptionsError("a width or a height is required")
+	}
+	if !r.IsAllowed(o.Width, o.Height) {
+		return o, NewInvalidOptionsError("size %dx%d is not allowed", o.Width, o.Height)
+	}
+
+	o.Fit = query.Get("fit")
+	switch o.Fit {
+	case "":
+		o.Fit = Cover
+	case Cover, Contain, Fill:
+	default:
+		return o, NewInvalidOptionsError("invalid fit: %s", o.Fit)
+	}
+
+	o.Format = query.Get("format")
+	if o.Format == "jpg" {
+		o.Format = JPEG
+	}
+	if _, ok := extensions[o.Format]; !ok && o.Format != "" {
+		return o, NewInvalidOptionsError("invalid format: %s", o.Format)
+	}
+	return o, nil
+}
+
+// Check to see if images are allowed to be resized to the supplied size
+func (r *Resizer) IsAllowed(width, height int) bool {
+	for _, size := range r.sizes {
+		if size.Width == width && size.Height == height {
+			return true
+		}
+	}
+	return false
+}
+
+// Figure out the path of the cached version of the supplied image. The modification time and size of the
+// image is part of the key, so that a changed image results in a new cached version
+func (r *Resizer) cachePath(path string, info os.FileInfo, o Options) string {
+	key := fmt.Sprintf("%s|%d|%d|%d|%d|%s|%s", path, info.ModTime().UnixNano(), info.Size(), o.Width, o.Height,
+		o.Fit, o.Format)
+	hash := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(r.directory, name[:2], name+extensions[o.Format])
+}
+
+// Fetch a resized version of the image at the supplied uri, for example "/assets/media/photo.jpg". Will return
+// the path to the cached version of the resized image
+func (r *Resizer) Resize(ctx context.Context, uri string, o Options) (string, error) {
+	path := filepath.Join(r.root, filepath.FromSlash(uri))
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", &UnsupportedImageError{Path: uri, Reason: "is a directory"}
+	}
+
+	cfg, format, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return "", &UnsupportedImageError{Path: uri, Reason: err.Error()}
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return "", &UnsupportedImageError{Path: uri, Reason: "too many pixels"}
+	}
+	if o.Format == "" {
+		o.Format = format
+	}
+	if _, ok := extensions[o.Format]; !ok {
+		return "", &UnsupportedImageError{Path: uri, Reason: "cannot encode " + o.Format}
+	}
+
+	cached := r.cachePath(uri, info, o)
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+
+	r.sem <- struct{}{}
+	defer func() { <-r.sem }()
+
+	// Another request might have resized the image while waiting
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(bufio.NewReader(f))
+	if err != nil {
+		return "", &UnsupportedImageError{Path: uri, Reason: err.Error()}
+	}
+
+	log.Infof(ctx, "Resizing %s to %dx%d (%s) as %s", uri, o.Width, o.Height, o.Fit, o.Format)
+	if err := r.write(cached, Resize(src, o), o.Format); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// Encode the supplied image into the cache. The image is written to a temporary file first, so that other
+// requests never see a partially written image
+func (r *Resizer) write(path string, img *image.RGBA, format string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	switch format {
+	case JPEG:
+		// JPEG images can't be transparent. Transparent parts are drawn on white instead of black
+		opaque := image.NewRGBA(img.Bounds())
+		draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), img, image.Point{}, draw.Over)
+		err = jpeg.Encode(w, opaque, &jpeg.Options{Quality: r.Quality})
+	case PNG:
+		err = png.Encode(w, img)
+	case GIF:
+		err = gif.Encode(w, img, nil)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Create a new resizer for images found in the supplied content directory. Static files are expected to be served
+// with the supplied uri prefix, for example "/assets". The widths of all presets are allowed sizes
+func NewResizer(contentDirectory string, staticPrefix string, cfg config.ImageConfig) *Resizer {
+	quality := cfg.Quality
+	if quality < 1 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	sizes := append([]config.ImageSizeConfig{}, cfg.Sizes...)
+	for _, preset := range cfg.Presets {
+		for _, width := range preset.Widths {
+			sizes = append(sizes, config.ImageSizeConfig{Width: width})
+		}
+	}
+	return &Resizer{
+		Quality:      quality,
+		root:         contentDirectory,
+		staticPrefix: strings.TrimSuffix(staticPrefix, "/"),
+		uriPrefix:    strings.TrimSuffix(cfg.URIPrefix, "/"),
+		directory:    cfg.CacheDirectory,
+		sizes:        sizes,
+		presets:      cfg.Presets,
+		sem:          make(chan struct{}, runtime.NumCPU()),
+	}
+}
@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A resized version of an image
+type Source struct {
+	// The URL of the resized image
+	URL string
+	// The width of the resized image
+	Width int
+}
+
+// An image available in multiple widths, which lets the browser pick the most suitable one
+type ResponsiveImage struct {
+	// The URL used by browsers that don't support srcset
+	Src string
+	// All widths the image is available in, smallest first
+	Sources []Source
+	// The value of the sizes attribute
+	Sizes string
+	// The intrinsic width of the image
+	Width int
+	// The intrinsic height of the image
+	Height int
+}
+
+// Read the intrinsic dimensions of the image at the supplied uri, for example "/assets/media/photo.jpg"
+func (r *Resizer) Dimensions(uri string) (int, int, error) {
+	f, err := os.Open(filepath.Join(r.root, filepath.FromSlash(uri)))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return 0, 0, &UnsupportedImageError{Path: uri, Reason: err.Error()}
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Figure out the URL of the supplied image resized to the supplied width
+func (r *Resizer) URL(uri string, width int) string {
+	return escapePath(r.uriPrefix+strings.TrimPrefix(uri, r.staticPrefix)) + "?w=" + strconv.Itoa(width)
+}
+
+// Escape the supplied path, so that it can be used in a srcset attribute where spaces and commas are separators
+func escapePath(path string) string {
+	return strings.ReplaceAll((&url.URL{Path: path}).EscapedPath(), ",", "%2C")
+}
+
+// Create a responsive version of the image at the supplied uri, for example "/assets/media/photo.jpg", using the
+// widths of the supplied preset. Images are never scaled up, which means that widths larger than the image itself
+// are replaced by the original image
+func (r *Resizer) ResponsiveImage(uri string, preset string) (*ResponsiveImage, error) {
+	p, ok := r.presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("image preset '%s' is not found", preset)
+	}
+	if !strings.HasPrefix(uri, r.staticPrefix+"/") {
+		return nil, fmt.Errorf("image '%s' is not a static file", uri)
+	}
+
+	width, height, err := r.Dimensions(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	widths := append([]int{}, p.Widths...)
+	sort.Ints(widths)
+	result := &ResponsiveImage{Src: escapePath(uri), Sizes: p.Sizes, Width: width, Height: height}
+	for _, w := range widths {
+		if w >= width {
+			break
+		}
+		result.Sources = append(result.Sources, Source{URL: r.URL(uri, w), Width: w})
+	}
+	result.Sources = append(result.Sources, Source{URL: escapePath(uri), Width: width})
+	if len(result.Sources) > 1 {
+		result.Src = result.Sources[len(result.Sources)-2].URL
+	}
+	return result, nil
+}
@@ -31,6 +31,12 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+// Create a context containing the logging values of the supplied context. The created context is never cancelled,
+// which means that it's used for work that must be finished even if the request that started it is cancelled
+func Detach(ctx context.Context) context.Context {
+	return SetUserName(SetRequestID(context.Background(), GetRequestID(ctx)), GetUserName(ctx))
+}
+
 // Create a logger that contains useful context information
 func FromContext(ctx context.Context) logrus.FieldLogger {
 	id := GetRequestID(ctx)
@@ -44,19 +50,19 @@ func LogFromRequest(r *http.Request) logrus.FieldLogger {
 }
 
 func Infof(ctx context.Context, format string, args ...interface{}) {
-	FromContext(ctx).Infof(format, args)
+	FromContext(ctx).Infof(format, args...)
 }
 
 func Warnf(ctx context.Context, format string, args ...interface{}) {
-	FromContext(ctx).Warnf(format, args)
+	FromContext(ctx).Warnf(format, args...)
 }
 
 func Errorf(ctx context.Context, format string, args ...interface{}) {
-	FromContext(ctx).Errorf(format, args)
+	FromContext(ctx).Errorf(format, args...)
 }
 
 func Debugf(ctx context.Context, format string, args ...interface{}) {
 	if logrus.IsLevelEnabled(logrus.DebugLevel) {
-		FromContext(ctx).Debugf(format, args)
+		FromContext(ctx).Debugf(format, args...)
 	}
 }
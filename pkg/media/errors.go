@@ -0,0 +1,60 @@
+package media
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error raised when a file could not be found in the media library
+type NotFoundError struct {
+	ID string
+}
+
+func (n *NotFoundError) Error() string {
+	return fmt.Sprintf("media '%s' is not found", n.ID)
+}
+
+// Error raised when the type of an uploaded file isn't allowed in the media library
+type UnsupportedTypeError struct {
+	ContentType string
+}
+
+func (u *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("files of type '%s' are not allowed", u.ContentType)
+}
+
+// Error raised when an uploaded file is larger than allowed
+type TooLargeError struct {
+	MaxSize int64
+}
+
+func (t *TooLargeError) Error() string {
+	return fmt.Sprintf("files larger than %d bytes are not allowed", t.MaxSize)
+}
+
+// Error raised when a file is deleted while pages still reference it
+type InUseError struct {
+	// The file being deleted
+	File File
+	// The paths of the pages that reference the file
+	Pages []string
+}
+
+func (i *InUseError) Error() string {
+	return fmt.Sprintf("media '%s' is used by: %s", i.File.Path, strings.Join(i.Pages, ", "))
+}
+
+// Error raised when the media library could not be loaded
+type LoadError struct {
+	message string
+}
+
+func (l *LoadError) Error() string {
+	return l.message
+}
+
+func NewLoadError(format string, v ...interface{}) *LoadError {
+	return &LoadError{
+		message: fmt.Sprintf(format, v...),
+	}
+}
@@ -0,0 +1,381 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The content types allowed in the media library and the file extension used for each of them. The content type
+// is sniffed from the uploaded data, which means that the name of the uploaded file is never trusted
+var AllowedTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"video/mp4":       ".mp4",
+	"audio/mpeg":      ".mp3",
+}
+
+// The maximum length of a file name, excluding the extension
+const maxNameLength = 100
+
+// A file in the media library
+type File struct {
+	// Unique ID of the file. This is the hex encoded SHA-256 hash of the content
+	ID string
+	// The name of the file on disk
+	Name string
+	// The URI where the file is served, for example "/assets/media/photo.jpg"
+	Path string
+	// The sniffed content type
+	ContentType string
+	// The size of the file in bytes
+	Size int64
+	// The width of the image. Zero if the file isn't an image or if the image format isn't supported
+	Width int `json:",omitempty"`
+	// The height of the image. Zero if the file isn't an image or if the image format isn't supported
+	Height int `json:",omitempty"`
+	// Alternative text describing the image
+	Alt string
+	// The name of the user that uploaded the file
+	UploadedBy string
+	// When the file was uploaded
+	UploadedAt time.Time
+}
+
+// Library of uploaded files. The files are saved in the media directory, which is part of the content, and the
+// metadata of all files is saved in a json database. Each upload and delete is committed to the content repository
+type Library struct {
+	// The maximum size of an uploaded file in bytes
+	MaxSize int64
+
+	repository content.Repository
+	controller *content.GitController
+	// The media directory and the database, relative to the content directory. Only these are committed
+	paths     []string
+	directory string
+	uriPrefix string
+	// The URI prefix where resized versions of the files are served, for example "/assets/img/media"
+	resizedURIPrefix string
+	databasePath     string
+	mux              sync.Mutex
+	files            map[string]*File
+}
+
+// Make the supplied file name safe to use both on disk and in URLs. The extension is replaced with the supplied one
+func sanitizeName(name string, ext string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+
+	var builder strings.Builder
+	dash := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash {
+			builder.WriteRune('-')
+			dash = true
+		}
+	}
+
+	result := strings.Trim(builder.String(), "-_")
+	if len(result) > maxNameLength {
+		result = strings.TrimRight(result[:maxNameLength], "-_")
+	}
+	if result == "" {
+		result = "file"
+	}
+	return result + ext
+}
+
+// Fetch the dimensions of the supplied image. Will return zero if the image format isn't supported
+func dimensions(data []byte) (int, int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// Figure out a name not used by any other file. The mutex must be held when this is called
+func (l *Library) uniqueName(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	taken := func(name string) bool {
+		for _, f := range l.files {
+			if f.Name == name {
+				return true
+			}
+		}
+		_, err := os.Stat(filepath.Join(l.directory, name))
+		return err == nil
+	}
+	for i := 2; taken(name); i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+// Write the supplied data to a temporary file before moving it into place, so that a failed write never leaves
+// a partially written file behind
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Save the metadata of all files. The mutex must be held when this is called
+func (l *Library) save() error {
+	files := make([]*File, 0, len(l.files))
+	for _, f := range l.files {
+		files = append(files, f)
+	}
+	sortFiles(files)
+
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.databasePath), 0755); err != nil {
+		return err
+	}
+	return writeFile(l.databasePath, data)
+}
+
+// Sort the supplied files, newest first
+func sortFiles(files []*File) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].UploadedAt.Equal(files[j].UploadedAt) {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].UploadedAt.After(files[j].UploadedAt)
+	})
+}
+
+// Add the supplied data to the media library. Files are de-duplicated by their content, which means that the
+// already uploaded file is returned if the same content is uploaded again. The returned bool is true if a new
+// file is added
+func (l *Library) Upload(ctx context.Context, author content.Author, name string, data []byte,
+	alt string) (*File, bool, error) {
+	if int64(len(data)) > l.MaxSize {
+		return nil, false, &TooLargeError{MaxSize: l.MaxSize}
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := AllowedTypes[contentType]
+	if !ok {
+		return nil, false, &UnsupportedTypeError{ContentType: contentType}
+	}
+
+	hash := sha256.Sum256(data)
+	id := hex.EncodeToString(hash[:])
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if existing, ok := l.files[id]; ok {
+		result := *existing
+		return &result, false, nil
+	}
+
+	if err := os.MkdirAll(l.directory, 0755); err != nil {
+		return nil, false, err
+	}
+	name = l.uniqueName(sanitizeName(name, ext))
+	path := filepath.Join(l.directory, name)
+	if err := writeFile(path, data); err != nil {
+		return nil, false, err
+	}
+
+	width, height := dimensions(data)
+	file := &File{
+		ID:          id,
+		Name:        name,
+		Path:        l.uriPrefix + "/" + name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       width,
+		Height:      height,
+		Alt:         alt,
+		UploadedBy:  log.GetUserName(ctx),
+		UploadedAt:  time.Now().UTC(),
+	}
+	l.files[id] = file
+	if err := l.save(); err != nil {
+		delete(l.files, id)
+		_ = os.Remove(path)
+		return nil, false, err
+	}
+
+	log.Infof(ctx, "Uploaded %s to the media library", file.Path)
+	l.commit(ctx, "Upload "+file.Path, author)
+	result := *file
+	return &result, true, nil
+}
+
+// Fetch all files in the media library, newest first
+func (l *Library) List() []*File {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	result := make([]*File, 0, len(l.files))
+	for _, f := range l.files {
+		copied := *f
+		result = append(result, &copied)
+	}
+	sortFiles(result)
+	return result
+}
+
+// Search for the file with the supplied ID
+func (l *Library) Find(id string) (*File, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if f, ok := l.files[id]; ok {
+		result := *f
+		return &result, nil
+	}
+	return nil, &NotFoundError{ID: id}
+}
+
+// Search for the paths of all pages that reference the supplied file, either directly or by using a resized version
+// of it
+func (l *Library) References(file *File) []string {
+	uris := [][]byte{[]byte(file.Path)}
+	if l.resizedURIPrefix != "" {
+		uris = append(uris, []byte(l.resizedURIPrefix+strings.TrimPrefix(file.Path, l.uriPrefix)))
+	}
+
+	var result []string
+	for _, page := range l.repository.GetAll() {
+		data, err := json.Marshal(page.Model)
+		if err != nil {
+			continue
+		}
+		for _, uri := range uris {
+			if bytes.Contains(data, uri) {
+				result = append(result, page.Path)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Delete the file with the supplied ID. An InUseError is returned if pages still reference the file
+func (l *Library) Delete(ctx context.Context, author content.Author, id string) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	file, ok := l.files[id]
+	if !ok {
+		return &NotFoundError{ID: id}
+	}
+	if pages := l.References(file); len(pages) > 0 {
+		return &InUseError{File: *file, Pages: pages}
+	}
+
+	delete(l.files, id)
+	if err := l.save(); err != nil {
+		l.files[id] = file
+		return err
+	}
+	if err := os.Remove(filepath.Join(l.directory, file.Name)); err != nil && !os.IsNotExist(err) {
+		log.Warnf(ctx, "Could not remove %s from the media library: %v", file.Name, err)
+	}
+	log.Infof(ctx, "Deleted %s from the media library", file.Path)
+	l.commit(ctx, "Delete "+file.Path, author)
+	return nil
+}
+
+// Commit the media directory and the database, so that changes to the library are not part of the next save of
+// the content. The change is kept if the commit fails, in which case it's committed together with the next change
+func (l *Library) commit(ctx context.Context, message string, author content.Author) {
+	if err := l.controller.CommitPaths(ctx, message, author, l.paths...); err != nil {
+		log.Warnf(ctx, "Could not commit changes to the media library: %v", err)
+	}
+}
+
+func (l *Library) load(ctx context.Context) error {
+	log.Infof(ctx, "Loading media library from %s", l.databasePath)
+	files := make(map[string]*File)
+	data, err := ioutil.ReadFile(l.databasePath)
+	if err != nil && !os.IsNotExist(err) {
+		return NewLoadError("Could not read media library: '%s' because: %v", l.databasePath, err)
+	}
+
+	if err == nil {
+		var list []*File
+		if err := json.Unmarshal(data, &list); err != nil {
+			return NewLoadError("Could not parse media library: '%s' because: %v", l.databasePath, err)
+		}
+		for _, f := range list {
+			files[f.ID] = f
+		}
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.files = files
+	return nil
+}
+
+func (l *Library) OnEvent(ctx context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		if err := l.load(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create a new media library where files are saved in the content directory of the supplied controller. Pages in
+// the supplied repository are searched for references before a file is deleted. References to resized versions of
+// the files, served with the supplied URI prefix, are also searched for unless the prefix is empty
+func NewLibrary(bus *event.Bus, repository content.Repository, controller *content.GitController,
+	config config.MediaConfig, resizedURIPrefix string) *Library {
+	contentDirectory := controller.RootPath
+	impl := &Library{
+		MaxSize:          config.MaxSize,
+		repository:       repository,
+		controller:       controller,
+		paths:            []string{config.Directory, config.DatabasePath},
+		directory:        filepath.Join(contentDirectory, config.Directory),
+		uriPrefix:        strings.TrimSuffix(config.URIPrefix, "/"),
+		resizedURIPrefix: strings.TrimSuffix(resizedURIPrefix, "/"),
+		databasePath:     filepath.Join(contentDirectory, config.DatabasePath),
+		mux:              sync.Mutex{},
+		files:            make(map[string]*File),
+	}
+	err := impl.load(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	bus.AddListener(impl)
+	return impl
+}
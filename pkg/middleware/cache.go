@@ -5,6 +5,8 @@ import (
 	"github.com/westcoastcode-se/gocms/pkg/cache"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type cachedResponseWriter struct {
@@ -25,22 +27,85 @@ func (c *cachedResponseWriter) WriteHeader(statusCode int) {
 	c.statusCode = statusCode
 }
 
-func Cache(cache cache.Pages, next http.Handler) http.Handler {
+// Check to see if the client already has the page with the supplied ETag, by looking at the If-None-Match and
+// If-Modified-Since headers. If-Modified-Since is ignored if the request contains If-None-Match
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		if t, err := http.ParseTime(ifModifiedSince); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
+
+// Write the supplied cache entry to the response. The ETag depends on the content encoding, since each compressed
+// version of the page is a different representation of it. Clients that already have the page get
+// 304 Not Modified instead
+func writeEntry(rw http.ResponseWriter, r *http.Request, encoders []Encoder, entry *cache.Entry) {
+	header := rw.Header()
+	for key, values := range entry.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	contentType := contentType(entry.Header, entry.Content)
+	header.Set("Content-Type", contentType)
+	body := selectEncoded(rw, r, encoders, contentType, entry.Content, entry.Encoded)
+
+	if entry.StatusCode == http.StatusOK {
+		etag := entry.Hash
+		if encoding := header.Get("Content-Encoding"); encoding != "" {
+			etag += "-" + encoding
+		}
+		etag = `"` + etag + `"`
+		header.Set("ETag", etag)
+		header.Set("Last-Modified", entry.LastModified.Format(http.TimeFormat))
+
+		if isNotModified(r, etag, entry.LastModified) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			header.Del("Content-Encoding")
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	rw.WriteHeader(entry.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = rw.Write(body)
+	}
+}
+
+// Serve pages from the supplied cache. Pages are compressed with all encoders when they are cached, which means that
+// cache hits never have to compress the page again. Cache hits are served with the headers of the original response,
+// an ETag and Last-Modified, so that conditional and HEAD requests never have to render the page. Pages that are
+// not allowed to be cached are compressed for each request
+func Cache(pages cache.Pages, encoders []Encoder, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		uri := r.URL.Path
 
 		// Ignore if caching is not allowed
-		if !cache.IsAllowed(uri) {
-			next.ServeHTTP(rw, r)
+		if !pages.IsAllowed(uri) {
+			Compress(encoders, next).ServeHTTP(rw, r)
 			return
 		}
 
 		// Return the cached result if found
-		if cachedResult, err := cache.Find(uri); err == nil {
-			contentLength := len(cachedResult)
-			rw.Header().Set("Content-Length", strconv.Itoa(contentLength))
-			rw.WriteHeader(http.StatusOK)
-			_, _ = rw.Write(cachedResult)
+		if entry, err := pages.Find(uri); err == nil {
+			writeEntry(rw, r, encoders, entry)
 			return
 		}
 
@@ -49,21 +114,21 @@ func Cache(cache cache.Pages, next http.Handler) http.Handler {
 			buffer:     bytes.Buffer{},
 			statusCode: 200,
 		}
-		next.ServeHTTP(wrapper, r)
+		next.ServeHTTP(wrapper, r.WithContext(cache.SetShared(r.Context())))
 
 		b := wrapper.buffer.Bytes()
-		if wrapper.statusCode < 400 {
-			cache.Set(uri, b)
-		}
+		contentType := contentType(wrapper.header, b)
+		wrapper.header.Set("Content-Type", contentType)
+		entry := cache.NewEntry(wrapper.statusCode, wrapper.header, b, Encode(r, encoders, contentType, b))
 
-		for key, values := range wrapper.header {
-			for _, value := range values {
-				rw.Header().Set(key, value)
-			}
+		// Pages that set cookies are specific to a single user and are never cached
+		cookies := wrapper.header["Set-Cookie"]
+		if wrapper.statusCode < 400 && len(cookies) == 0 {
+			pages.Set(uri, entry)
+		}
+		for _, cookie := range cookies {
+			rw.Header().Add("Set-Cookie", cookie)
 		}
-		
-		rw.Header().Set("Content-Length", strconv.Itoa(len(b)))
-		rw.WriteHeader(wrapper.statusCode)
-		_, _ = rw.Write(b)
+		writeEntry(rw, r, encoders, entry)
 	})
 }
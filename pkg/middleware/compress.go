@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses smaller than this are not compressed, since the compressed response might be larger than the original
+const minCompressSize = 256
+
+// Encoder used when compressing responses. Add custom encoders, such as brotli, to support more content encodings
+type Encoder interface {
+	// The name of the content encoding, for example "gzip"
+	Encoding() string
+
+	// Compress the supplied content
+	Encode(content []byte) ([]byte, error)
+}
+
+// Encoder that compresses responses using gzip
+type GzipEncoder struct {
+	Level int
+}
+
+func (g *GzipEncoder) Encoding() string {
+	return "gzip"
+}
+
+func (g *GzipEncoder) Encode(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Create a new encoder that compresses responses using gzip
+func NewGzipEncoder() *GzipEncoder {
+	return &GzipEncoder{Level: gzip.DefaultCompression}
+}
+
+// Check to see if responses of the supplied content type are worth compressing. Images, for example, are
+// already compressed
+func IsCompressible(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasSuffix(contentType, "+json") ||
+		strings.HasSuffix(contentType, "+xml") ||
+		contentType == "application/json" ||
+		contentType == "application/javascript" ||
+		contentType == "application/xml" ||
+		contentType == "image/svg+xml"
+}
+
+// Figure out how much the client accepts the supplied content encoding, between 0 and 1
+func acceptQuality(r *http.Request, encoding string) float64 {
+	quality := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[len("q="):], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		// An explicit encoding is more specific than "*"
+		if name == encoding {
+			return q
+		}
+		quality = q
+	}
+	return quality
+}
+
+// Search for the encoder preferred by the client. The first encoder wins if the client accepts multiple encoders
+// equally. Will return nil if the client doesn't accept any of the encoders
+func Negotiate(r *http.Request, encoders []Encoder) Encoder {
+	var result Encoder
+	best := 0.0
+	for _, encoder := range encoders {
+		if q := acceptQuality(r, encoder.Encoding()); q > best {
+			result = encoder
+			best = q
+		}
+	}
+	return result
+}
+
+// Check to see if the supplied content is worth compressing
+func shouldCompress(contentType string, content []byte) bool {
+	return len(content) >= minCompressSize && IsCompressible(contentType)
+}
+
+// Compress the supplied content with all encoders. Will return nil if the content isn't worth compressing
+func Encode(r *http.Request, encoders []Encoder, contentType string, content []byte) map[string][]byte {
+	if !shouldCompress(contentType, content) {
+		return nil
+	}
+
+	result := make(map[string][]byte)
+	for _, encoder := range encoders {
+		encoded, err := encoder.Encode(content)
+		if err != nil {
+			log.Warnf(r.Context(), "Could not compress %s using %s: %v", r.URL.Path, encoder.Encoding(), err)
+			continue
+		}
+		result[encoder.Encoding()] = encoded
+	}
+	return result
+}
+
+// Select the supplied content, or one of its compressed versions if the client accepts it, and set the encoding
+// headers of the response. The response varies on the Accept-Encoding header if the content is worth compressing,
+// even if the client doesn't accept any compressed version
+func selectEncoded(rw http.ResponseWriter, r *http.Request, encoders []Encoder, contentType string, content []byte,
+	encoded map[string][]byte) []byte {
+	if !shouldCompress(contentType, content) {
+		return content
+	}
+
+	rw.Header().Add("Vary", "Accept-Encoding")
+	if encoder := Negotiate(r, encoders); encoder != nil {
+		if b, ok := encoded[encoder.Encoding()]; ok {
+			rw.Header().Set("Content-Encoding", encoder.Encoding())
+			return b
+		}
+	}
+	return content
+}
+
+// Write the supplied content, or one of its compressed versions if the client accepts it, to the response
+func writeEncoded(rw http.ResponseWriter, r *http.Request, encoders []Encoder, statusCode int, contentType string,
+	content []byte, encoded map[string][]byte) {
+	body := selectEncoded(rw, r, encoders, contentType, content, encoded)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	rw.WriteHeader(statusCode)
+	_, _ = rw.Write(body)
+}
+
+// Figure out the content type of the supplied response. The content type is sniffed from the content if the
+// response doesn't have one
+func contentType(header http.Header, content []byte) string {
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(content)
+}
+
+// Compress responses using the encoder preferred by the client
+func Compress(encoders []Encoder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		wrapper := &cachedResponseWriter{
+			header:     make(http.Header),
+			buffer:     bytes.Buffer{},
+			statusCode: 200,
+		}
+		next.ServeHTTP(wrapper, r)
+
+		b := wrapper.buffer.Bytes()
+		for key, values := range wrapper.header {
+			for _, value := range values {
+				rw.Header().Add(key, value)
+			}
+		}
+		if wrapper.header.Get("Content-Encoding") != "" {
+			rw.WriteHeader(wrapper.statusCode)
+			_, _ = rw.Write(b)
+			return
+		}
+
+		contentType := contentType(wrapper.header, b)
+		rw.Header().Set("Content-Type", contentType)
+		var encoded map[string][]byte
+		if encoder := Negotiate(r, encoders); encoder != nil {
+			encoded = Encode(r, []Encoder{encoder}, contentType, b)
+		}
+		writeEncoded(rw, r, encoders, wrapper.statusCode, contentType, b, encoded)
+	})
+}
@@ -0,0 +1,281 @@
+package preview
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/asset"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/form"
+	"github.com/westcoastcode-se/gocms/pkg/imaging"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/render"
+	"github.com/westcoastcode-se/gocms/pkg/render/html"
+	"github.com/westcoastcode-se/gocms/pkg/render/html/immediate"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Worktrees are named after the commit followed by a sequence number, since a commit can be checked out again
+// while the worktree of an evicted snapshot is still in use
+var worktreeName = regexp.MustCompile(`^[0-9a-f]{40}(-[0-9]+)?$`)
+
+// The content of a specific commit, checked out in a separate git worktree
+type Snapshot struct {
+	// The commit checked out in the snapshot
+	Commit string
+	// Repository containing the pages of the snapshot
+	Repository content.Repository
+	// Used for figuring out what parts of the snapshot requires what user roles
+	ACL acl.Service
+	// Renderers that use the templates of the snapshot
+	TemplateRenderers *render.TemplateRenderers
+
+	dir string
+	// The number of times the snapshot has been found without being released
+	refs int
+	// Set when the snapshot is no longer kept. The worktree is removed once the snapshot is released
+	evicted bool
+}
+
+// A snapshot that is being created. Requests for the same commit wait for it to be created instead of creating
+// their own
+type creation struct {
+	done chan struct{}
+	err  error
+}
+
+// Previews of git refs. Each previewed commit is checked out in a separate git worktree, which means that the
+// live content is left untouched. The least recently used worktrees are removed when the limit is reached
+type Previews struct {
+	controller *content.GitController
+	live       content.Repository
+	liveACL    acl.Service
+	config     config.Config
+	directory  string
+	limit      int
+
+	mux       sync.Mutex
+	snapshots map[string]*list.Element
+	creations map[string]*creation
+	lru       *list.List
+	created   int
+}
+
+// Search for a snapshot of the supplied ref, such as a branch name, tag or commit. The ref is checked out into a
+// new worktree if no snapshot of the commit exists. The snapshot must be released when it's no longer used
+func (p *Previews) Find(ctx context.Context, ref string) (*Snapshot, error) {
+	commit, err := p.controller.ResolveCommit(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.mux.Lock()
+		if element, ok := p.snapshots[commit]; ok {
+			p.lru.MoveToFront(element)
+			snapshot := element.Value.(*Snapshot)
+			snapshot.refs++
+			p.mux.Unlock()
+			return snapshot, nil
+		}
+
+		if c, ok := p.creations[commit]; ok {
+			p.mux.Unlock()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if c.err != nil {
+				return nil, c.err
+			}
+			continue
+		}
+
+		c := &creation{done: make(chan struct{})}
+		p.creations[commit] = c
+		p.created++
+		dir := filepath.Join(p.directory, fmt.Sprintf("%s-%d", commit, p.created))
+		p.mux.Unlock()
+
+		// The lock is not held while the worktree is created, since checking out and loading a commit is slow
+		snapshot, err := p.create(ctx, commit, dir)
+
+		p.mux.Lock()
+		delete(p.creations, commit)
+		var evicted []*Snapshot
+		if err == nil {
+			snapshot.refs = 1
+			p.snapshots[commit] = p.lru.PushFront(snapshot)
+			evicted = p.evict()
+		}
+		c.err = err
+		close(c.done)
+		p.mux.Unlock()
+
+		p.remove(ctx, evicted)
+		return snapshot, err
+	}
+}
+
+// Release a snapshot returned by Find. The worktree of an evicted snapshot is removed once it's no longer used
+func (p *Previews) Release(ctx context.Context, snapshot *Snapshot) {
+	p.mux.Lock()
+	snapshot.refs--
+	unused := snapshot.evicted && snapshot.refs == 0
+	p.mux.Unlock()
+
+	if unused {
+		p.remove(ctx, []*Snapshot{snapshot})
+	}
+}
+
+// Evict the least recently used snapshots until the limit is reached. Will return the evicted snapshots that are
+// not in use. The lock must be held
+func (p *Previews) evict() []*Snapshot {
+	var unused []*Snapshot
+	for p.lru.Len() > p.limit {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		snapshot := oldest.Value.(*Snapshot)
+		delete(p.snapshots, snapshot.Commit)
+		snapshot.evicted = true
+		if snapshot.refs == 0 {
+			unused = append(unused, snapshot)
+		}
+	}
+	return unused
+}
+
+// Remove the worktrees of the supplied snapshots
+func (p *Previews) remove(ctx context.Context, snapshots []*Snapshot) {
+	for _, snapshot := range snapshots {
+		log.Infof(ctx, "Removing preview of %s", snapshot.Commit)
+		p.removeWorktree(ctx, snapshot.dir)
+	}
+}
+
+// Remove the supplied worktree. The worktree is removed even if the request that caused the removal is cancelled
+func (p *Previews) removeWorktree(ctx context.Context, dir string) {
+	if err := p.controller.RemoveWorktree(context.Background(), dir); err != nil {
+		log.Warnf(ctx, "Could not remove preview %s: %v", dir, err)
+	}
+}
+
+// Checkout the supplied commit into a new worktree and load the content found in it. The worktree is removed if
+// the content can't be loaded
+func (p *Previews) create(ctx context.Context, commit string, dir string) (*Snapshot, error) {
+	log.Infof(ctx, "Creating preview of %s in %s", commit, dir)
+	if err := p.controller.AddWorktree(ctx, dir, commit); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := p.load(ctx, commit, dir)
+	if err != nil {
+		p.removeWorktree(ctx, dir)
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Load the content found in the worktree at the supplied directory
+func (p *Previews) load(ctx context.Context, commit string, dir string) (*Snapshot, error) {
+	// The snapshot has its own bus, so that it doesn't react to checkouts of the live content
+	bus := event.NewBus()
+	repository := content.NewRepository(bus, filepath.Join(dir, "pages"))
+	for name, fn := range p.live.GetUnmarshalFuncs() {
+		repository.RegisterModelType(name, fn)
+	}
+	if err := repository.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	aclService := p.liveACL
+	contentDirectory := filepath.Clean(p.config.ContentDirectory) + string(filepath.Separator)
+	aclPath := filepath.Clean(p.config.ACLDatabasePath)
+	if strings.HasPrefix(aclPath, contentDirectory) {
+		aclPath = filepath.Join(dir, aclPath[len(contentDirectory):])
+		if _, err := os.Stat(aclPath); err == nil {
+			if aclService, err = acl.LoadFileBasedACL(bus, aclPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	forms, err := form.LoadDatabase(bus, filepath.Join(dir, "forms"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Static files are read from the worktree, so that the templates of the snapshot use the stylesheets and
+	// scripts of the same commit. Nothing is cached, since the snapshot is short-lived
+	cfg := p.config
+	cfg.ContentDirectory = dir
+	templateRenderers := render.NewTemplateRenderers()
+	templateRenderers.AddFactory(".html", &html.TemplateRendererFactory{
+		ContentRepository: repository,
+		TemplateDatabase:  immediate.NewFileSystemTemplateDatabase(filepath.Join(dir, "templates")),
+		ACL:               aclService,
+		Forms:             forms,
+		Images:            imaging.NewResizer(dir, cfg.StaticURIPrefix, cfg.Images),
+		Assets:            asset.NewPipeline(bus, dir, cfg.StaticURIPrefix, false),
+		Bundles:           asset.NewBundles(bus, dir, cfg.StaticURIPrefix+"/bundles", cfg.Bundles, false),
+		Config:            cfg,
+	})
+
+	return &Snapshot{
+		Commit:            commit,
+		Repository:        repository,
+		ACL:               aclService,
+		TemplateRenderers: templateRenderers,
+		dir:               dir,
+	}, nil
+}
+
+// Create a new container for previews. Worktrees are created in the supplied directory and at most limit
+// worktrees are kept at the same time. Worktrees left from earlier runs are removed.
+func NewPreviews(controller *content.GitController, live content.Repository, liveACL acl.Service,
+	config config.Config, directory string, limit int) *Previews {
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		panic(err)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	ctx := context.Background()
+	if dirs, err := ioutil.ReadDir(directory); err == nil {
+		for _, dir := range dirs {
+			if dir.IsDir() && worktreeName.MatchString(dir.Name()) {
+				if err := os.RemoveAll(filepath.Join(directory, dir.Name())); err != nil {
+					log.Warnf(ctx, "Could not remove old preview %s: %v", dir.Name(), err)
+				}
+			}
+		}
+	}
+	if err := controller.PruneWorktrees(ctx); err != nil {
+		log.Warnf(ctx, "Could not prune old previews: %v", err)
+	}
+
+	return &Previews{
+		controller: controller,
+		live:       live,
+		liveACL:    liveACL,
+		config:     config,
+		directory:  directory,
+		limit:      limit,
+		mux:        sync.Mutex{},
+		snapshots:  make(map[string]*list.Element),
+		creations:  make(map[string]*creation),
+		lru:        list.New(),
+	}
+}
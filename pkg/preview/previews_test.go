@@ -0,0 +1,282 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"github.com/westcoastcode-se/gocms/pkg/asset"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A content checkout with a local bare repository as its remote
+type testRepository struct {
+	t    *testing.T
+	root string
+	work string
+}
+
+func (r *testRepository) git(args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@localhost"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.work
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Commit the supplied files and push them to the supplied branch of the remote. Will return the commit
+func (r *testRepository) commit(branch string, files map[string]string) string {
+	r.t.Helper()
+	for name, data := range files {
+		path := filepath.Join(r.work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("add", "--all")
+	r.git("commit", "-m", "Update "+branch)
+	r.git("push", "origin", "HEAD:refs/heads/"+branch)
+	return r.git("rev-parse", "HEAD")
+}
+
+// Check to see if the supplied git worktree is registered in the checkout
+func (r *testRepository) hasWorktree(dir string) bool {
+	r.t.Helper()
+	return strings.Contains(r.git("worktree", "list", "--porcelain"), "worktree "+dir+"\n")
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	root, err := ioutil.TempDir("", "previews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+	// Git reports worktrees with symbolic links resolved
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &testRepository{t: t, root: root, work: filepath.Join(root, "work")}
+	if err := os.MkdirAll(r.work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.git("init", "--bare", filepath.Join(root, "origin.git"))
+	r.git("init")
+	r.git("remote", "add", "origin", filepath.Join(root, "origin.git"))
+	r.commit("main", map[string]string{"pages/index.json": page("Live")})
+	return r
+}
+
+func page(title string) string {
+	return `{"View": "views/index.html", "Meta": {"Title": "` + title + `"}}`
+}
+
+func newPreviews(r *testRepository, limit int) *Previews {
+	bus := event.NewBus()
+	controller := content.NewGitController(bus, r.work)
+	live := content.NewRepository(bus, filepath.Join(r.work, "pages"))
+	cfg := config.Config{
+		ContentDirectory: r.work,
+		ACLDatabasePath:  filepath.Join(r.work, "config", "acl.json"),
+		StaticURIPrefix:  "/assets",
+		Bundles: map[string]config.BundleConfig{
+			"site.css": {Files: []string{"/assets/css/site.css"}},
+		},
+	}
+	return NewPreviews(controller, live, acl.NewFileBasedACL(bus, ""), cfg, filepath.Join(r.root, "previews"), limit)
+}
+
+func title(t *testing.T, snapshot *Snapshot) string {
+	t.Helper()
+	model, err := snapshot.Repository.FindByPath("/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.Meta.Title
+}
+
+func TestFindCreatesSnapshotOfRemoteBranch(t *testing.T) {
+	r := newTestRepository(t)
+	commit := r.commit("feature", map[string]string{"pages/index.json": page("Feature")})
+	// The branch is only found in the remote
+	r.git("update-ref", "-d", "refs/remotes/origin/feature")
+
+	previews := newPreviews(r, 2)
+	ctx := context.Background()
+	snapshot, err := previews.Find(ctx, "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Commit != commit {
+		t.Errorf("expected commit %s but got %s", commit, snapshot.Commit)
+	}
+	if title(t, snapshot) != "Feature" {
+		t.Errorf("expected the content of the branch but got %q", title(t, snapshot))
+	}
+
+	same, err := previews.Find(ctx, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != snapshot {
+		t.Error("expected the snapshot to be reused")
+	}
+	previews.Release(ctx, same)
+	previews.Release(ctx, snapshot)
+	if !r.hasWorktree(snapshot.dir) {
+		t.Error("expected released snapshots to be kept until they are evicted")
+	}
+}
+
+func TestFindRemovesWorktreeWhenContentIsMalformed(t *testing.T) {
+	for name, file := range map[string]string{"acl": "config/acl.json", "form": "forms/contact.json"} {
+		t.Run(name, func(t *testing.T) {
+			r := newTestRepository(t)
+			commit := r.commit("broken", map[string]string{file: "{"})
+
+			previews := newPreviews(r, 2)
+			if _, err := previews.Find(context.Background(), commit); err == nil {
+				t.Fatal("expected malformed content to fail")
+			}
+			if out := r.git("worktree", "list", "--porcelain"); strings.Count(out, "worktree ") != 1 {
+				t.Errorf("expected the worktree to be removed but got:\n%s", out)
+			}
+			if dirs, _ := ioutil.ReadDir(previews.directory); len(dirs) != 0 {
+				t.Errorf("expected the preview directory to be empty but found %s", dirs[0].Name())
+			}
+		})
+	}
+}
+
+func TestEvictedSnapshotIsRemovedWhenReleased(t *testing.T) {
+	r := newTestRepository(t)
+	first := r.commit("first", map[string]string{"pages/index.json": page("First")})
+	second := r.commit("second", map[string]string{"pages/index.json": page("Second")})
+
+	previews := newPreviews(r, 1)
+	ctx := context.Background()
+	inUse, err := previews.Find(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := previews.Find(ctx, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previews.Release(ctx, other)
+
+	if !r.hasWorktree(inUse.dir) || title(t, inUse) != "First" {
+		t.Fatal("expected an evicted snapshot to be kept while it's in use")
+	}
+
+	again, err := previews.Find(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == inUse || again.dir == inUse.dir {
+		t.Error("expected an evicted snapshot to be checked out again")
+	}
+	if r.hasWorktree(other.dir) {
+		t.Error("expected an unused snapshot to be removed when evicted")
+	}
+
+	previews.Release(ctx, inUse)
+	if r.hasWorktree(inUse.dir) {
+		t.Error("expected an evicted snapshot to be removed when released")
+	}
+	if !r.hasWorktree(again.dir) {
+		t.Error("expected the new snapshot to be kept")
+	}
+	previews.Release(ctx, again)
+}
+
+func TestConcurrentFindCreatesOneSnapshot(t *testing.T) {
+	r := newTestRepository(t)
+	commit := r.commit("feature", map[string]string{"pages/index.json": page("Feature")})
+
+	previews := newPreviews(r, 2)
+	ctx := context.Background()
+	snapshots := make([]*Snapshot, 8)
+	var wg sync.WaitGroup
+	for i := range snapshots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snapshot, err := previews.Find(ctx, commit)
+			if err != nil {
+				t.Error(err)
+			}
+			snapshots[i] = snapshot
+		}(i)
+	}
+	wg.Wait()
+
+	for _, snapshot := range snapshots {
+		if snapshot != snapshots[0] {
+			t.Fatal("expected all requests to share the same snapshot")
+		}
+	}
+	if snapshots[0].refs != len(snapshots) {
+		t.Errorf("expected %d references but got %d", len(snapshots), snapshots[0].refs)
+	}
+	for _, snapshot := range snapshots {
+		previews.Release(ctx, snapshot)
+	}
+}
+
+func TestSnapshotUsesStaticFilesOfCommit(t *testing.T) {
+	r := newTestRepository(t)
+	live := r.commit("main", map[string]string{
+		"assets/css/site.css":  "body { color: red; }",
+		"templates/index.html": `<style>{{IncludeCSS "/assets/css/site.css"}}</style><link href="{{Bundle "site.css"}}">`,
+	})
+	commit := r.commit("feature", map[string]string{"assets/css/site.css": "body { color: blue; }"})
+	r.git("checkout", "--detach", live)
+
+	previews := newPreviews(r, 2)
+	ctx := context.Background()
+	snapshot, err := previews.Find(ctx, commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer previews.Release(ctx, snapshot)
+
+	factory, err := snapshot.TemplateRenderers.FindFactory("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	model, _ := snapshot.Repository.FindByPath("/index")
+	if err := factory.NewRenderer(httptest.NewRequest("GET", "/", nil)).RenderView(&buf, "index.html", model); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "color: blue") {
+		t.Errorf("expected the stylesheet of the commit to be included but got %s", output)
+	}
+
+	liveBundles := asset.NewBundles(event.NewBus(), r.work, "/assets/bundles", previews.config.Bundles, false)
+	liveURL, err := liveBundles.URL("site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `href="/assets/bundles/site.`) || strings.Contains(output, liveURL) {
+		t.Errorf("expected the bundle of the commit but got %s", output)
+	}
+}
@@ -0,0 +1,30 @@
+package html
+
+import (
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/imaging"
+	"html/template"
+	"strings"
+)
+
+// Generate an <img> tag for the supplied responsive image. The width and height attributes are only written if
+// the dimensions of the image are known
+func responsiveImage(image *imaging.ResponsiveImage, alt string) template.HTML {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, `<img src="%s"`, template.HTMLEscapeString(image.Src))
+	if len(image.Sources) > 0 {
+		srcset := make([]string, len(image.Sources))
+		for i, source := range image.Sources {
+			srcset[i] = fmt.Sprintf("%s %dw", source.URL, source.Width)
+		}
+		_, _ = fmt.Fprintf(&sb, ` srcset="%s"`, template.HTMLEscapeString(strings.Join(srcset, ", ")))
+	}
+	if image.Sizes != "" {
+		_, _ = fmt.Fprintf(&sb, ` sizes="%s"`, template.HTMLEscapeString(image.Sizes))
+	}
+	if image.Width > 0 && image.Height > 0 {
+		_, _ = fmt.Fprintf(&sb, ` width="%d" height="%d"`, image.Width, image.Height)
+	}
+	_, _ = fmt.Fprintf(&sb, ` alt="%s">`, template.HTMLEscapeString(alt))
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,75 @@
+package html
+
+import (
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"html/template"
+	"strings"
+)
+
+// Convert the site configuration into metadata defaults
+func siteMeta(site config.SiteConfig) content.Meta {
+	return content.Meta{
+		Title:       site.Title,
+		Description: site.Description,
+		Robots:      site.Robots,
+		Image:       site.Image,
+	}
+}
+
+// Figure out the canonical URL for the page at the supplied uri. Each page in a paginated list has its own
+// canonical URL
+func canonicalURL(site config.SiteConfig, meta content.Meta, uri string, page int) string {
+	if meta.CanonicalURL != "" {
+		uri = meta.CanonicalURL
+	}
+	return site.AbsoluteURL(content.PageURI(uri, page))
+}
+
+// Generate rel=prev and rel=next links for the supplied pagination
+func paginationLinks(site config.SiteConfig, pagination *content.Pagination) template.HTML {
+	var sb strings.Builder
+	if pagination.HasPrev() {
+		_, _ = fmt.Fprintf(&sb, "<link rel=\"prev\" href=\"%s\">\n",
+			template.HTMLEscapeString(site.AbsoluteURL(pagination.PrevURL)))
+	}
+	if pagination.HasNext() {
+		_, _ = fmt.Fprintf(&sb, "<link rel=\"next\" href=\"%s\">\n",
+			template.HTMLEscapeString(site.AbsoluteURL(pagination.NextURL)))
+	}
+	return template.HTML(sb.String())
+}
+
+// Generate the meta tags, used in the <head> block, for the page at the supplied uri
+func metaTags(site config.SiteConfig, meta content.Meta, uri string, page int) template.HTML {
+	var sb strings.Builder
+	writeTag := func(format string, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(&sb, format+"\n", template.HTMLEscapeString(value))
+		}
+	}
+
+	canonical := canonicalURL(site, meta, uri, page)
+	image := site.AbsoluteURL(meta.Image)
+	twitterCard := "summary"
+	if image != "" {
+		twitterCard = "summary_large_image"
+	}
+
+	writeTag(`<title>%s</title>`, meta.Title)
+	writeTag(`<meta name="description" content="%s">`, meta.Description)
+	writeTag(`<meta name="robots" content="%s">`, meta.Robots)
+	writeTag(`<link rel="canonical" href="%s">`, canonical)
+	writeTag(`<meta property="og:site_name" content="%s">`, site.Name)
+	writeTag(`<meta property="og:title" content="%s">`, meta.Title)
+	writeTag(`<meta property="og:description" content="%s">`, meta.Description)
+	writeTag(`<meta property="og:url" content="%s">`, canonical)
+	writeTag(`<meta property="og:type" content="%s">`, "website")
+	writeTag(`<meta property="og:image" content="%s">`, image)
+	writeTag(`<meta name="twitter:card" content="%s">`, twitterCard)
+	writeTag(`<meta name="twitter:title" content="%s">`, meta.Title)
+	writeTag(`<meta name="twitter:description" content="%s">`, meta.Description)
+	writeTag(`<meta name="twitter:image" content="%s">`, image)
+	return template.HTML(sb.String())
+}
@@ -3,7 +3,6 @@ package html
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"github.com/westcoastcode-se/gocms/pkg/log"
 	"html/template"
@@ -27,7 +26,7 @@ func (h *TemplateRenderer) RenderView(writer io.Writer, view string, model inter
 			buf := bytes.NewBuffer([]byte{})
 			err := h.RenderView(buf, childView, childModel)
 			if err != nil {
-				log.Errorf(h.ctx, "Failed to render child view %s: %e", childView, err)
+				log.Errorf(h.ctx, "Failed to render child view %s: %v", childView, err)
 				return "", err
 			}
 			return template.HTML(buf.String()), nil
@@ -36,12 +35,12 @@ func (h *TemplateRenderer) RenderView(writer io.Writer, view string, model inter
 
 	err := h.templateDatabase.ParseTemplates(t)
 	if err != nil {
-		return errors.New(fmt.Sprintf("could not parse templates: %e", err))
+		return fmt.Errorf("could not parse templates: %w", err)
 	}
 
 	err = t.ExecuteTemplate(writer, view, model)
 	if err != nil {
-		return errors.New(fmt.Sprintf("could not execute template with template "+view+": %e", err))
+		return fmt.Errorf("could not execute template with template %s: %w", view, err)
 	}
 
 	return nil
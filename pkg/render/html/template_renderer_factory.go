@@ -1,30 +1,56 @@
 package html
 
 import (
+	"fmt"
+	"github.com/westcoastcode-se/gocms/pkg/asset"
+	"github.com/westcoastcode-se/gocms/pkg/cache"
 	"github.com/westcoastcode-se/gocms/pkg/config"
 	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/form"
+	"github.com/westcoastcode-se/gocms/pkg/imaging"
 	"github.com/westcoastcode-se/gocms/pkg/log"
 	"github.com/westcoastcode-se/gocms/pkg/render"
 	"github.com/westcoastcode-se/gocms/pkg/security"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
 	"github.com/westcoastcode-se/gocms/pkg/security/jwt"
 	"html/template"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"sort"
 )
 
 type TemplateRendererFactory struct {
 	ContentRepository content.Repository
 	TemplateDatabase  TemplateDatabase
+	ACL               acl.Service
+	Forms             *form.Database
+	Images            *imaging.Resizer
+	Assets            *asset.Pipeline
+	Bundles           *asset.Bundles
 	Config            config.Config
 }
 
+// Fetch the path on disk of the file with the supplied uri, for example "/assets/css/site.css"
+func (h *TemplateRendererFactory) contentPath(uri string) string {
+	return filepath.Join(h.Config.ContentDirectory, filepath.FromSlash(path.Clean("/"+uri)))
+}
+
 func (h *TemplateRendererFactory) NewRenderer(r *http.Request) render.TemplateRenderer {
-	uri := r.URL.Path
-	user, _ := r.Context().Value(jwt.SessionKey).(*security.User)
+	uri := content.GetPagePath(r.Context())
+	if uri == "" {
+		uri = r.URL.Path
+	}
+	pageNumber := content.GetPageNumber(r.Context())
+	user, ok := r.Context().Value(jwt.SessionKey).(*security.User)
+	if !ok {
+		user = security.NotLoggedInUser
+	}
+	viewer := cache.Viewer(r.Context(), user)
 	funcs := template.FuncMap{
-		"Navigation": func() *content.Navigation { return &content.Navigation{uri} },
+		"Navigation": func() *content.Navigation { return &content.Navigation{URI: uri} },
 		"Author":     func() bool { return h.Config.Author },
 		"Public":     func() bool { return !h.Config.Author },
 		"User":       func() *security.User { return user },
@@ -48,10 +74,76 @@ func (h *TemplateRendererFactory) NewRenderer(r *http.Request) render.TemplateRe
 		"Limit": func(limit int, a []*content.SearchResult) []*content.SearchResult {
 			return a[0:limit]
 		},
+		"Archives": func(contentType string) []*content.ArchiveYear {
+			var prefix string
+			for _, archive := range h.Config.Archives {
+				if archive.Type == contentType {
+					prefix = archive.Prefix
+					break
+				}
+			}
+			var items []*content.SearchResult
+			for _, item := range h.ContentRepository.Search(contentType) {
+				if acl.IsAccessible(h.ACL, viewer, item.Path) {
+					items = append(items, item)
+				}
+			}
+			return content.BuildArchives(items, prefix)
+		},
+		"Form": func(id string) (template.HTML, error) {
+			definition, err := h.Forms.Find(id)
+			if err != nil {
+				return "", err
+			}
+			return definition.Render("/api/v1/forms/" + definition.ID), nil
+		},
+		"Asset": func(uri string) string {
+			if h.Assets == nil {
+				return uri
+			}
+			return h.Assets.URL(uri)
+		},
+		"Bundle": func(name string) (string, error) {
+			if h.Bundles == nil {
+				return "", fmt.Errorf("bundle '%s' is not found", name)
+			}
+			return h.Bundles.URL(name)
+		},
+		"InlineBundle": func(name string) (interface{}, error) {
+			if h.Bundles == nil {
+				return "", fmt.Errorf("bundle '%s' is not found", name)
+			}
+			bundle, err := h.Bundles.Find(name)
+			if err != nil {
+				return "", err
+			}
+			if path.Ext(name) == ".css" {
+				return template.CSS(bundle.Content), nil
+			}
+			return template.JS(bundle.Content), nil
+		},
+		"ResponsiveImage": func(src string, alt string, preset string) template.HTML {
+			if h.Images == nil {
+				return responsiveImage(&imaging.ResponsiveImage{Src: src}, alt)
+			}
+			image, err := h.Images.ResponsiveImage(src, preset)
+			if err != nil {
+				log.Warnf(r.Context(), "Could not create responsive image of %s: %v", src, err)
+				image = &imaging.ResponsiveImage{Src: src}
+			}
+			return responsiveImage(image, alt)
+		},
+		"Paginate": func(pageSize int, items []*content.SearchResult) (*content.Pagination, error) {
+			content.MarkPaginated(r.Context())
+			return content.Paginate(items, pageSize, pageNumber, uri)
+		},
+		"PaginationLinks": func(pagination *content.Pagination) template.HTML {
+			return paginationLinks(h.Config.Site, pagination)
+		},
 		"RenderScript": func(view string) template.JS {
 			view = view[:len(view)-5]
 			path := "/assets/js/" + view + ".js"
-			absolutePath := "./content" + path
+			absolutePath := h.contentPath(path)
 			_, err := os.Stat(absolutePath)
 			if os.IsNotExist(err) {
 				return ""
@@ -59,25 +151,25 @@ func (h *TemplateRendererFactory) NewRenderer(r *http.Request) render.TemplateRe
 
 			b, err := ioutil.ReadFile(absolutePath)
 			if err != nil {
-				log.Warnf(r.Context(), "Could not include file: %s. Reason: %e", path, err)
+				log.Warnf(r.Context(), "Could not include file: %s. Reason: %v", path, err)
 				return ""
 			}
 			return template.JS(b)
 		},
 		"IncludeCSS": func(path string) template.CSS {
-			absolutePath := "content" + path
+			absolutePath := h.contentPath(path)
 			bytes, err := ioutil.ReadFile(absolutePath)
 			if err != nil {
-				log.Warnf(r.Context(), "Could not include file: %s. Reason: %e", path, err)
+				log.Warnf(r.Context(), "Could not include file: %s. Reason: %v", path, err)
 				return ""
 			}
 			return template.CSS(bytes)
 		},
 		"IncludeScript": func(path string) template.JS {
-			absolutePath := "content" + path
+			absolutePath := h.contentPath(path)
 			bytes, err := ioutil.ReadFile(absolutePath)
 			if err != nil {
-				log.Warnf(r.Context(), "Could not include file: %s. Reason: %e", path, err)
+				log.Warnf(r.Context(), "Could not include file: %s. Reason: %v", path, err)
 				return ""
 			}
 			html := template.JS(bytes)
@@ -86,6 +178,45 @@ func (h *TemplateRendererFactory) NewRenderer(r *http.Request) render.TemplateRe
 		"Lookup": func(id string) *content.SearchResult {
 			return h.ContentRepository.Lookup(id)
 		},
+		"Page": func() *content.SearchResult {
+			model, err := h.ContentRepository.FindByPath(uri)
+			if err != nil {
+				return nil
+			}
+			return &content.SearchResult{Path: uri, Model: model}
+		},
+		"Related": func(page interface{}, n int) []*content.SearchResult {
+			var path string
+			switch p := page.(type) {
+			case *content.SearchResult:
+				path = p.Path
+			case *content.Model:
+				if result := h.ContentRepository.Lookup(p.ID); result != nil {
+					path = result.Path
+				}
+			case string:
+				path = p
+			}
+
+			var result []*content.SearchResult
+			for _, related := range h.ContentRepository.Related(path, -1) {
+				if len(result) == n {
+					break
+				}
+				if acl.IsAccessible(h.ACL, viewer, related.Path) {
+					result = append(result, related)
+				}
+			}
+			return result
+		},
+		"MetaTags": func() template.HTML {
+			meta := content.ResolveMeta(h.ContentRepository, uri, siteMeta(h.Config.Site))
+			return metaTags(h.Config.Site, meta, uri, pageNumber)
+		},
+		"CanonicalURL": func() string {
+			meta := content.ResolveMeta(h.ContentRepository, uri, siteMeta(h.Config.Site))
+			return canonicalURL(h.Config.Site, meta, uri, pageNumber)
+		},
 	}
 
 	return &TemplateRenderer{r.Context(), h.TemplateDatabase, funcs}
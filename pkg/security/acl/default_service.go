@@ -51,13 +51,13 @@ func (f *DefaultService) load(ctx context.Context) error {
 	log.Infof(ctx, "Loading ACL from %s", f.databasePath)
 	bytes, err := ioutil.ReadFile(f.databasePath)
 	if err != nil {
-		return NewLoadError("Could not read database file: '%s' because: %e", f.databasePath, err)
+		return NewLoadError("Could not read database file: '%s' because: %v", f.databasePath, err)
 	}
 
 	var body entryBody
 	err = json.Unmarshal(bytes, &body)
 	if err != nil {
-		return NewLoadError("Could not parse database file: '%s' because: %e", f.databasePath, err)
+		return NewLoadError("Could not parse database file: '%s' because: %v", f.databasePath, err)
 	}
 
 	var database = make(map[string]entry)
@@ -82,17 +82,25 @@ func (f *DefaultService) OnEvent(ctx context.Context, e interface{}) error {
 
 // Create a new file-based ACL service.
 func NewFileBasedACL(bus *event.Bus, path string) Service {
+	impl, err := LoadFileBasedACL(bus, path)
+	if err != nil {
+		panic(err)
+	}
+	return impl
+}
+
+// Load a new file-based ACL service. Will return a LoadError if the database file can't be read or parsed
+func LoadFileBasedACL(bus *event.Bus, path string) (Service, error) {
 	impl := &DefaultService{
 		databasePath: path,
 		mux:          sync.Mutex{},
 		Database:     make(map[string]entry),
 	}
 	if len(path) > 0 {
-		err := impl.load(context.Background())
-		if err != nil {
-			panic(err)
+		if err := impl.load(context.Background()); err != nil {
+			return nil, err
 		}
 	}
 	bus.AddListener(impl)
-	return impl
+	return impl, nil
 }
@@ -13,6 +13,6 @@ func (l *LoadError) Error() string {
 
 func NewLoadError(format string, v ...interface{}) *LoadError {
 	return &LoadError{
-		message: fmt.Sprintf(format, v),
+		message: fmt.Sprintf(format, v...),
 	}
 }
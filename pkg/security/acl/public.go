@@ -0,0 +1,13 @@
+package acl
+
+import "github.com/westcoastcode-se/gocms/pkg/security"
+
+// Check to see if the supplied user is allowed to access the supplied uri
+func IsAccessible(service Service, user *security.User, uri string) bool {
+	return user.HasRoles(service.GetRoles(uri))
+}
+
+// Check to see if the supplied uri is accessible by users that are not logged in
+func IsPublic(service Service, uri string) bool {
+	return IsAccessible(service, security.NotLoggedInUser, uri)
+}
@@ -22,6 +22,6 @@ func (l *LoadError) Error() string {
 
 func NewLoadError(format string, v ...interface{}) *LoadError {
 	return &LoadError{
-		message: fmt.Sprintf(format, v),
+		message: fmt.Sprintf(format, v...),
 	}
 }
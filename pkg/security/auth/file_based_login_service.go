@@ -13,6 +13,7 @@ import (
 type userData struct {
 	Username string
 	Password string
+	Email    string
 	Roles    []string
 }
 
@@ -46,6 +47,7 @@ func (s *FileBasedLoginService) Login(username string, password string) (*securi
 		if user.Username == username && user.Password == encoded {
 			return &security.User{
 				Name:  user.Username,
+				Email: user.Email,
 				Roles: user.Roles,
 			}, nil
 		}
@@ -59,13 +61,13 @@ func (s *FileBasedLoginService) Login(username string, password string) (*securi
 func (s *FileBasedLoginService) load() error {
 	bytes, err := ioutil.ReadFile(s.databasePath)
 	if err != nil {
-		return NewLoadError("could not read database file: '%s' because: '%e'", s.databasePath, err)
+		return NewLoadError("could not read database file: '%s' because: '%v'", s.databasePath, err)
 	}
 
 	var body entryBody
 	err = json.Unmarshal(bytes, &body)
 	if err != nil {
-		return NewLoadError("could not parse database file: '%s' because: '%e'", s.databasePath, err)
+		return NewLoadError("could not parse database file: '%s' because: '%v'", s.databasePath, err)
 	}
 
 	var users []userData
@@ -73,6 +75,7 @@ func (s *FileBasedLoginService) load() error {
 		users = append(users, userData{
 			Username: u.Username,
 			Password: u.Password,
+			Email:    u.Email,
 			Roles:    u.Roles,
 		})
 	}
@@ -9,6 +9,7 @@ const (
 
 type Claims struct {
 	Name  string
+	Email string
 	Roles []string
 	jwt.StandardClaims
 }
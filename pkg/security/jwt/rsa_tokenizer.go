@@ -19,6 +19,7 @@ func (r *RsaTokenizer) UserToToken(user *security.User) (string, error) {
 	expirationTime := now.Add(5 * time.Minute)
 	claims := &Claims{
 		Name:  user.Name,
+		Email: user.Email,
 		Roles: user.Roles,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
@@ -50,7 +51,7 @@ func (r *RsaTokenizer) TokenToUser(tokenAsString string) (*security.User, error)
 		return security.NotLoggedInUser, errors.New("token is no longer valid")
 	}
 
-	return &security.User{Name: claims.Subject, Roles: claims.Roles}, nil
+	return &security.User{Name: claims.Subject, Email: claims.Email, Roles: claims.Roles}, nil
 }
 
 // Create a new asymmetric tokenizer instance used.
@@ -10,12 +10,14 @@ const (
 type User struct {
 	// The name of the user
 	Name string
+	// The email address of the user
+	Email string
 	// The roles the user has access too
 	Roles []string
 }
 
 // Represents a user that's not logged in
-var NotLoggedInUser = &User{"", []string{Read}}
+var NotLoggedInUser = &User{Name: "", Roles: []string{Read}}
 
 // Check to see if user is logged in
 func (u *User) IsLoggedIn() bool {
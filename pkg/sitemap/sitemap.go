@@ -0,0 +1,187 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/content"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"github.com/westcoastcode-se/gocms/pkg/security/acl"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The maximum number of URLs allowed in a single sitemap
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Function used for fetching the time when each page was last modified. The result is keyed by the page path
+type LastModifiedFunc func(ctx context.Context) (map[string]time.Time, error)
+
+type url struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []url    `xml:"url"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	XMLNS    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// Generator of sitemaps based on the pages found in the content repository. Generated sitemaps are cached
+// until new content is checked out.
+type Generator struct {
+	repository   content.Repository
+	acl          acl.Service
+	lastModified LastModifiedFunc
+	site         config.SiteConfig
+	mux          sync.Mutex
+	documents    map[string][]byte
+}
+
+// Fetch the sitemap document for the supplied uri, for example "/sitemap.xml". If the site contains more than
+// MaxURLs pages then "/sitemap.xml" is a sitemap index referring to "/sitemap-1.xml", "/sitemap-2.xml" and so on.
+// A content.NotFoundError is returned if no such document exists.
+func (g *Generator) Find(ctx context.Context, uri string) ([]byte, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.documents == nil {
+		documents, err := g.generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		g.documents = documents
+	}
+
+	if document, ok := g.documents[uri]; ok {
+		return document, nil
+	}
+	return nil, content.NewNotFoundError(uri)
+}
+
+// Forcefully reset all generated sitemaps
+func (g *Generator) Reset() {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.documents = nil
+}
+
+func (g *Generator) OnEvent(_ context.Context, e interface{}) error {
+	if _, ok := e.(*event.Checkout); ok {
+		g.Reset()
+	}
+	return nil
+}
+
+func (g *Generator) generate(ctx context.Context) (map[string][]byte, error) {
+	var lastModified map[string]time.Time
+	if g.lastModified != nil {
+		var err error
+		lastModified, err = g.lastModified(ctx)
+		if err != nil {
+			log.Warnf(ctx, "Could not figure out when pages were last modified. Reason: %v", err)
+		}
+	}
+
+	var urls []url
+	for _, page := range g.repository.GetAll() {
+		if !acl.IsPublic(g.acl, page.Path) {
+			continue
+		}
+
+		meta := content.ResolveMeta(g.repository, page.Path, content.Meta{Robots: g.site.Robots})
+		if meta.NoIndex() {
+			continue
+		}
+
+		loc := page.Path
+		if meta.CanonicalURL != "" {
+			loc = meta.CanonicalURL
+		}
+
+		var lastMod string
+		if t, ok := lastModified[page.Path]; ok {
+			lastMod = t.UTC().Format(time.RFC3339)
+		}
+		urls = append(urls, url{Loc: g.site.AbsoluteURL(loc), LastMod: lastMod})
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].Loc < urls[j].Loc
+	})
+
+	documents := make(map[string][]byte)
+	if len(urls) <= MaxURLs {
+		b, err := marshal(&urlSet{XMLNS: namespace, URLs: urls})
+		if err != nil {
+			return nil, err
+		}
+		documents["/sitemap.xml"] = b
+		return documents, nil
+	}
+
+	index := &sitemapIndex{XMLNS: namespace}
+	for i := 0; i*MaxURLs < len(urls); i++ {
+		end := (i + 1) * MaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		uri := "/sitemap-" + strconv.Itoa(i+1) + ".xml"
+		b, err := marshal(&urlSet{XMLNS: namespace, URLs: urls[i*MaxURLs : end]})
+		if err != nil {
+			return nil, err
+		}
+		documents[uri] = b
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: g.site.AbsoluteURL(uri)})
+	}
+
+	b, err := marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	documents["/sitemap.xml"] = b
+	return documents, nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// Check to see if the supplied uri might be a sitemap document
+func IsSitemap(uri string) bool {
+	return uri == "/sitemap.xml" || (strings.HasPrefix(uri, "/sitemap-") && strings.HasSuffix(uri, ".xml"))
+}
+
+// Create a new sitemap generator. Pages are excluded from the sitemap if they require more than the Read role
+// or if they are marked as "noindex".
+func NewGenerator(bus *event.Bus, repository content.Repository, service acl.Service, lastModified LastModifiedFunc,
+	site config.SiteConfig) *Generator {
+	impl := &Generator{
+		repository:   repository,
+		acl:          service,
+		lastModified: lastModified,
+		site:         site,
+	}
+	bus.AddListener(impl)
+	return impl
+}
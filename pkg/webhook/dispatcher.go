@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"github.com/westcoastcode-se/gocms/pkg/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Names of the events that can be sent to a webhook
+const (
+	Checkout      = "checkout"
+	Push          = "push"
+	PageSaved     = "page.saved"
+	FormSubmitted = "form.submitted"
+	PageLocked    = "page.locked"
+	PageUnlocked  = "page.unlocked"
+)
+
+// Headers sent with each delivery
+const (
+	EventHeader     = "X-Gocms-Event"
+	DeliveryHeader  = "X-Gocms-Delivery"
+	SignatureHeader = "X-Gocms-Signature"
+)
+
+// Status of a delivery
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	Failed    = "failed"
+)
+
+// The maximum number of deliveries kept in the delivery log
+const maxDeliveries = 500
+
+// The payload posted to a webhook
+type Payload struct {
+	// Unique ID of the delivery
+	ID string
+	// The name of the event, for example "checkout"
+	Event string
+	// When the event happened
+	CreatedAt time.Time
+	// The actual event
+	Data interface{}
+}
+
+// A delivery of an event to a webhook
+type Delivery struct {
+	ID           string
+	Event        string
+	URL          string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	CreatedAt    time.Time
+	DeliveredAt  time.Time
+}
+
+// Dispatcher that posts events sent on the bus to the configured webhooks. Deliveries are made asynchronously
+// and failed deliveries are retried with an exponential backoff
+type Dispatcher struct {
+	// Client used when posting events
+	Client *http.Client
+	// The maximum number of attempts made for each delivery
+	MaxAttempts int
+	// The time to wait before the first retry. The time is doubled for each retry
+	Backoff time.Duration
+
+	hooks      []config.WebhookConfig
+	mux        sync.Mutex
+	deliveries []*Delivery
+}
+
+// Figure out the name of the supplied event. Will return an empty string if the event can't be sent to a webhook
+func eventName(e interface{}) string {
+	switch e.(type) {
+	case *event.Checkout:
+		return Checkout
+	case *event.Push:
+		return Push
+	case *event.PageSaved:
+		return PageSaved
+	case *event.FormSubmitted:
+		return FormSubmitted
+	case *event.Locked:
+		return PageLocked
+	case *event.Unlocked:
+		return PageUnlocked
+	}
+	return ""
+}
+
+// Check to see if the supplied webhook subscribes to the supplied event
+func subscribes(hook config.WebhookConfig, name string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a signature of the supplied body. The result is the hex encoded HMAC-SHA256 prefixed with "sha256="
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Fetch the latest deliveries, newest first
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	result := make([]Delivery, len(d.deliveries))
+	for i, delivery := range d.deliveries {
+		result[len(d.deliveries)-1-i] = *delivery
+	}
+	return result
+}
+
+func (d *Dispatcher) addDelivery(delivery *Delivery) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.deliveries = append(d.deliveries, delivery)
+	if len(d.deliveries) > maxDeliveries {
+		d.deliveries = d.deliveries[len(d.deliveries)-maxDeliveries:]
+	}
+}
+
+func (d *Dispatcher) update(delivery *Delivery, fn func(delivery *Delivery)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	fn(delivery)
+}
+
+// Post the payload to the webhook. Will return the status code of the response
+func (d *Dispatcher) post(hook config.WebhookConfig, delivery *Delivery, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, hook config.WebhookConfig, delivery *Delivery, body []byte) {
+	backoff := d.Backoff
+	for attempt := 1; ; attempt++ {
+		code, err := d.post(hook, delivery, body)
+		d.update(delivery, func(delivery *Delivery) {
+			delivery.Attempts = attempt
+			delivery.ResponseCode = code
+			delivery.Error = ""
+			if err != nil {
+				delivery.Error = err.Error()
+			}
+		})
+
+		if err == nil {
+			d.update(delivery, func(delivery *Delivery) {
+				delivery.Status = Delivered
+				delivery.DeliveredAt = time.Now().UTC()
+			})
+			log.Infof(ctx, "Delivered %s event %s to %s", delivery.Event, delivery.ID, hook.URL)
+			return
+		}
+
+		if attempt >= d.MaxAttempts {
+			d.update(delivery, func(delivery *Delivery) {
+				delivery.Status = Failed
+			})
+			log.Errorf(ctx, "Could not deliver %s event %s to %s after %d attempts: %v", delivery.Event,
+				delivery.ID, hook.URL, attempt, err)
+			return
+		}
+
+		log.Warnf(ctx, "Could not deliver %s event %s to %s. Retrying in %v: %v", delivery.Event, delivery.ID,
+			hook.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) OnEvent(ctx context.Context, e interface{}) error {
+	name := eventName(e)
+	if name == "" {
+		return nil
+	}
+
+	for _, hook := range d.hooks {
+		if !subscribes(hook, name) {
+			continue
+		}
+
+		payload := &Payload{
+			ID:        uuid.New().String(),
+			Event:     name,
+			CreatedAt: time.Now().UTC(),
+			Data:      e,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Warnf(ctx, "Could not create %s payload for %s: %v", name, hook.URL, err)
+			continue
+		}
+
+		delivery := &Delivery{
+			ID:        payload.ID,
+			Event:     name,
+			URL:       hook.URL,
+			Status:    Pending,
+			CreatedAt: payload.CreatedAt,
+		}
+		d.addDelivery(delivery)
+		go d.deliver(ctx, hook, delivery, body)
+	}
+	return nil
+}
+
+// Create a new dispatcher that sends events to the supplied webhooks
+func NewDispatcher(bus *event.Bus, hooks []config.WebhookConfig) *Dispatcher {
+	impl := &Dispatcher{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		hooks:       hooks,
+		mux:         sync.Mutex{},
+	}
+	bus.AddListener(impl)
+	return impl
+}
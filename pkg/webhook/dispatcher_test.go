@@ -0,0 +1,210 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/westcoastcode-se/gocms/pkg/config"
+	"github.com/westcoastcode-se/gocms/pkg/event"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A webhook receiver that records all requests. The status codes are returned in order and the last status code
+// is repeated when there are no more
+type receiver struct {
+	mux      sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func (r *receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.times = append(r.times, time.Now())
+
+	status := r.statuses[0]
+	if len(r.statuses) > 1 {
+		r.statuses = r.statuses[1:]
+	}
+	rw.WriteHeader(status)
+}
+
+func newDispatcher(hooks ...config.WebhookConfig) (*event.Bus, *Dispatcher) {
+	bus := event.NewBus()
+	dispatcher := NewDispatcher(bus, hooks)
+	dispatcher.Backoff = 20 * time.Millisecond
+	dispatcher.MaxAttempts = 3
+	return bus, dispatcher
+}
+
+// Wait for all deliveries to either be delivered or failed
+func waitForDeliveries(t *testing.T, dispatcher *Dispatcher) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries := dispatcher.Deliveries()
+		done := true
+		for _, d := range deliveries {
+			if d.Status == Pending {
+				done = false
+			}
+		}
+		if done {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for deliveries")
+	return nil
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	bus, dispatcher := newDispatcher(config.WebhookConfig{URL: server.URL, Secret: "secret"})
+	if err := bus.NotifyAll(context.Background(), &event.PageSaved{Path: "/news", ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := waitForDeliveries(t, dispatcher)
+
+	if len(r.requests) != 1 {
+		t.Fatalf("expected one request but got %d", len(r.requests))
+	}
+	req, body := r.requests[0], r.bodies[0]
+	if signature := req.Header.Get(SignatureHeader); signature != Sign("secret", body) {
+		t.Errorf("unexpected signature %q", signature)
+	}
+	if Sign("other", body) == Sign("secret", body) {
+		t.Error("expected the signature to depend on the secret")
+	}
+	if req.Header.Get(EventHeader) != PageSaved {
+		t.Errorf("unexpected event header %q", req.Header.Get(EventHeader))
+	}
+
+	var payload struct {
+		ID    string
+		Event string
+		Data  event.PageSaved
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != PageSaved || payload.Data.Path != "/news" || payload.Data.ID != "1" {
+		t.Errorf("unexpected payload %s", body)
+	}
+	if req.Header.Get(DeliveryHeader) != payload.ID || deliveries[0].ID != payload.ID {
+		t.Errorf("expected delivery %s but got %s", payload.ID, req.Header.Get(DeliveryHeader))
+	}
+}
+
+func TestDeliveryIsNotSignedWithoutSecret(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	bus, dispatcher := newDispatcher(config.WebhookConfig{URL: server.URL})
+	_ = bus.NotifyAll(context.Background(), &event.Checkout{Commit: "abc"})
+	waitForDeliveries(t, dispatcher)
+
+	if signature := r.requests[0].Header.Get(SignatureHeader); signature != "" {
+		t.Errorf("expected no signature but got %q", signature)
+	}
+}
+
+func TestDeliveryIsRetriedWithBackoff(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	bus, dispatcher := newDispatcher(config.WebhookConfig{URL: server.URL})
+	_ = bus.NotifyAll(context.Background(), &event.Checkout{Commit: "abc"})
+	deliveries := waitForDeliveries(t, dispatcher)
+
+	if len(r.times) != 3 {
+		t.Fatalf("expected three attempts but got %d", len(r.times))
+	}
+	if gap := r.times[1].Sub(r.times[0]); gap < dispatcher.Backoff {
+		t.Errorf("expected the first retry after at least %v but got %v", dispatcher.Backoff, gap)
+	}
+	if gap := r.times[2].Sub(r.times[1]); gap < 2*dispatcher.Backoff {
+		t.Errorf("expected the second retry after at least %v but got %v", 2*dispatcher.Backoff, gap)
+	}
+
+	d := deliveries[0]
+	if d.Status != Delivered || d.Attempts != 3 || d.ResponseCode != http.StatusNoContent || d.Error != "" {
+		t.Errorf("unexpected delivery %+v", d)
+	}
+	if d.DeliveredAt.IsZero() {
+		t.Error("expected the delivery time to be set")
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	bus, dispatcher := newDispatcher(config.WebhookConfig{URL: server.URL})
+	_ = bus.NotifyAll(context.Background(), &event.Checkout{Commit: "abc"})
+	deliveries := waitForDeliveries(t, dispatcher)
+
+	if len(r.requests) != dispatcher.MaxAttempts {
+		t.Fatalf("expected %d attempts but got %d", dispatcher.MaxAttempts, len(r.requests))
+	}
+	d := deliveries[0]
+	if d.Status != Failed || d.Attempts != 3 || d.ResponseCode != http.StatusInternalServerError {
+		t.Errorf("unexpected delivery %+v", d)
+	}
+	if !strings.Contains(d.Error, "500") {
+		t.Errorf("expected the error to contain the status code but got %q", d.Error)
+	}
+}
+
+func TestOnlySubscribedEventsAreDelivered(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	bus, dispatcher := newDispatcher(config.WebhookConfig{URL: server.URL, Events: []string{Checkout}})
+	_ = bus.NotifyAll(context.Background(), &event.PageSaved{Path: "/news", ID: "1"})
+	_ = bus.NotifyAll(context.Background(), "not an event")
+	_ = bus.NotifyAll(context.Background(), &event.Checkout{Commit: "abc"})
+	deliveries := waitForDeliveries(t, dispatcher)
+
+	if len(deliveries) != 1 || deliveries[0].Event != Checkout {
+		t.Fatalf("expected a single checkout delivery but got %+v", deliveries)
+	}
+	if len(r.requests) != 1 || r.requests[0].Header.Get(EventHeader) != Checkout {
+		t.Fatalf("expected a single checkout request")
+	}
+}
+
+func TestDeliveryLogIsNewestFirstAndCapped(t *testing.T) {
+	_, dispatcher := newDispatcher()
+	for i := 0; i < maxDeliveries+10; i++ {
+		dispatcher.addDelivery(&Delivery{ID: string(rune('a' + i%26)), Attempts: i})
+	}
+
+	deliveries := dispatcher.Deliveries()
+	if len(deliveries) != maxDeliveries {
+		t.Fatalf("expected %d deliveries but got %d", maxDeliveries, len(deliveries))
+	}
+	if deliveries[0].Attempts != maxDeliveries+9 {
+		t.Errorf("expected the newest delivery first but got %d", deliveries[0].Attempts)
+	}
+	if deliveries[len(deliveries)-1].Attempts != 10 {
+		t.Errorf("expected the oldest deliveries to be dropped but got %d", deliveries[len(deliveries)-1].Attempts)
+	}
+}